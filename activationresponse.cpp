@@ -50,3 +50,16 @@ void activation_response_set_scope_data(_ActivationResponse *response, char *jso
         *error = strdup(e.what());
     }
 }
+
+int activation_response_get_status(_ActivationResponse *response) {
+    return static_cast<int>(reinterpret_cast<ActivationResponse*>(response)->status());
+}
+
+void activation_response_copy(_ActivationResponse *dest, _ActivationResponse *src) {
+    *reinterpret_cast<ActivationResponse*>(dest) =
+        *reinterpret_cast<ActivationResponse*>(src);
+}
+
+void destroy_activation_response(_ActivationResponse *response) {
+    delete reinterpret_cast<ActivationResponse*>(response);
+}
@@ -29,6 +29,17 @@ type ActivationResponse struct {
 	Result    *Result
 	Widgets   []PreviewWidget
 	ScopeData interface{}
+
+	// forwarded holds the response of another scope, returned
+	// unchanged to the client.
+	forwarded *C._ActivationResponse
+}
+
+func finalizeActivationResponse(r *ActivationResponse) {
+	if r.forwarded != nil {
+		C.destroy_activation_response(r.forwarded)
+	}
+	r.forwarded = nil
 }
 
 // NewActivationResponse creates an ActivationResponse with the given status
@@ -75,6 +86,10 @@ func NewActivationResponseUpdatePreview(widgets ...PreviewWidget) *ActivationRes
 }
 
 func (r *ActivationResponse) update(responsePtr *C._ActivationResponse) error {
+	if r.forwarded != nil {
+		C.activation_response_copy(responsePtr, r.forwarded)
+		return nil
+	}
 	switch r.Status {
 	case ActivationPerformQuery:
 		C.activation_response_init_query(responsePtr, r.Query.q)
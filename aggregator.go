@@ -0,0 +1,277 @@
+package scopes
+
+import (
+	"time"
+)
+
+// AggregatorOrdering selects the order an Aggregator pushes the
+// results of its children in.
+type AggregatorOrdering int
+
+const (
+	// OrderByArrival pushes results as soon as they are received
+	// from any child.
+	OrderByArrival AggregatorOrdering = iota
+
+	// OrderByChild pushes the results of each child together, in
+	// the order the children are listed.  Results from later
+	// children are held back until the earlier children finish.
+	OrderByChild
+)
+
+// Aggregator implements Search for aggregator scopes.  It sends the
+// query to the child scopes in parallel and merges their results into
+// the reply.
+//
+// Only the results of the children are merged.  They are pushed to
+// the categories of the aggregator, while the categories, departments
+// and filters registered by the children are dropped, as they would
+// clash with those of the aggregator.  Scopes wishing to forward them
+// can query their children with ScopeBase.SubSearch instead.
+//
+// A scope would typically embed an Aggregator and call its Search
+// method from its own:
+//
+//	func (s *MyScope) Search(query *scopes.CannedQuery, metadata *scopes.SearchMetadata, reply *scopes.SearchReply, cancelled <-chan bool) error {
+//	    return s.aggregator.Search(query, metadata, reply, cancelled)
+//	}
+type Aggregator struct {
+	// Base is the scope base of the aggregator, used to list and
+	// query the child scopes.
+	Base *ScopeBase
+
+	// ChildTimeout is the maximum time each child may take.  Once
+	// it expires, the child query is cancelled and the results
+	// received so far are kept.  If zero, children are not
+	// bounded.
+	ChildTimeout time.Duration
+
+	// Ordering selects the order results are pushed in.
+	Ordering AggregatorOrdering
+
+	// DedupKey returns the key identifying a result.  If set,
+	// Search enables de-duplication across the whole reply with
+	// SearchReply.SetDeduplication, so results whose key has
+	// already been pushed are dropped.  If nil, the
+	// de-duplication settings of the reply are left alone.
+	// DedupByURI is a suitable choice for most scopes.
+	DedupKey func(result *CategorisedResult) string
+
+	// Category returns the category of the aggregator a result of
+	// the given child should be pushed into.  If it returns nil,
+	// the result is dropped.  If Category is nil, a category is
+	// registered for each child, named after its display name.
+	Category func(reply *SearchReply, child *ChildScope, result *CategorisedResult) *Category
+}
+
+// DedupByURI identifies results by their URI.  It can be used as the
+// DedupKey of an Aggregator.
+func DedupByURI(result *CategorisedResult) string {
+	return result.URI()
+}
+
+// aggregatedEvent is a reply from the child at the given index.  A
+// nil event signals that the child has finished.
+type aggregatedEvent struct {
+	child int
+	event *SubSearchEvent
+	err   error
+}
+
+// Search sends the query to the child scopes selected by routeChild
+// and pushes their results to reply.  Errors from individual
+// children are logged, and an error is only returned if every child
+// failed.  Once Push reports that the client wants no further
+// results, the remaining children are cancelled and their results
+// discarded.
+func (a *Aggregator) Search(query *CannedQuery, metadata *SearchMetadata, reply *SearchReply, cancelled <-chan bool) error {
+	var children []*ChildScope
+	keywords := metadata.AggregatedKeywords()
+	for _, child := range a.Base.ChildScopes() {
+		if routeChild(child.Enabled(), child.Keywords(), keywords) {
+			children = append(children, child)
+		}
+	}
+	if len(children) == 0 {
+		return nil
+	}
+
+	if a.DedupKey != nil {
+		reply.SetDeduplication(DedupAcrossReply, a.DedupKey)
+	}
+	events := make(chan aggregatedEvent)
+	// stop is closed once the client wants no further results, to
+	// cancel the children still running.
+	stop := make(chan struct{})
+	stopped := false
+	for i, child := range children {
+		go a.searchChild(i, child, query, metadata, cancelled, stop, events)
+	}
+
+	categories := make(map[*ChildScope]*Category)
+	categoryFor := a.Category
+	if categoryFor == nil {
+		categoryFor = func(reply *SearchReply, child *ChildScope, result *CategorisedResult) *Category {
+			if cat, ok := categories[child]; ok {
+				return cat
+			}
+			cat := reply.RegisterCategory(child.Id(), child.Metadata().DisplayName, "", "")
+			categories[child] = cat
+			return cat
+		}
+	}
+	merger := newResultMerger(len(children), a.Ordering, func(child int, result *CategorisedResult) error {
+		cat := categoryFor(reply, children[child], result)
+		if cat == nil {
+			return nil
+		}
+		result.setCategory(cat)
+		err := reply.Push(result)
+		if !pushStopped(err) {
+			return err
+		}
+		// The client does not want further results.
+		if !stopped {
+			stopped = true
+			close(stop)
+		}
+		return nil
+	})
+
+	var firstErr error
+	failed := 0
+	for remaining := len(children); remaining > 0; {
+		ev := <-events
+		if ev.event != nil {
+			if ev.event.Result != nil && !stopped {
+				if err := merger.add(ev.child, ev.event.Result); err != nil {
+					reply.Logger().Warningf("Could not push result of %s: %v", children[ev.child].Id(), err)
+				}
+			}
+			continue
+		}
+		remaining--
+		if ev.err != nil && ev.err != ErrSubSearchCancelled {
+			reply.Logger().Warningf("Child scope %s failed: %v", children[ev.child].Id(), ev.err)
+			if firstErr == nil {
+				firstErr = ev.err
+			}
+			failed++
+		}
+		if stopped {
+			continue
+		}
+		if err := merger.finish(ev.child); err != nil {
+			reply.Logger().Warningf("Could not push result of %s: %v", children[ev.child].Id(), err)
+		}
+	}
+	if failed == len(children) {
+		return firstErr
+	}
+	return nil
+}
+
+// searchChild runs the subsearch of one child, forwarding its events
+// until it finishes.  The subsearch is cancelled if it times out, if
+// stop is closed or if the parent query is cancelled.
+func (a *Aggregator) searchChild(index int, child *ChildScope, query *CannedQuery, metadata *SearchMetadata, cancelled <-chan bool, stop <-chan struct{}, events chan<- aggregatedEvent) {
+	search, err := a.Base.SubSearch(child.Id(), query, metadata, cancelled)
+	if err != nil {
+		events <- aggregatedEvent{child: index, err: err}
+		return
+	}
+	var timeout <-chan time.Time
+	if a.ChildTimeout > 0 {
+		timer := time.NewTimer(a.ChildTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		select {
+		case event, ok := <-search.Events():
+			if !ok {
+				events <- aggregatedEvent{child: index, err: search.Err()}
+				return
+			}
+			events <- aggregatedEvent{child: index, event: event}
+		case <-timeout:
+			search.Cancel()
+			timeout = nil
+		case <-stop:
+			search.Cancel()
+			stop = nil
+		}
+	}
+}
+
+// routeChild reports whether a child scope should receive a query.
+// Disabled children are skipped, and when the aggregator is itself
+// being aggregated by keywords, only children sharing one of the
+// keywords are queried.
+func routeChild(enabled bool, childKeywords, queryKeywords []string) bool {
+	if !enabled {
+		return false
+	}
+	if len(queryKeywords) == 0 {
+		return true
+	}
+	for _, k := range childKeywords {
+		for _, q := range queryKeywords {
+			if k == q {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resultMerger orders the results of the children of an aggregator.
+type resultMerger struct {
+	ordering AggregatorOrdering
+	push     func(child int, result *CategorisedResult) error
+
+	// For OrderByChild, the child whose results are currently
+	// pushed, and the results held back for the others.
+	current  int
+	buffered [][]*CategorisedResult
+	finished []bool
+}
+
+func newResultMerger(children int, ordering AggregatorOrdering, push func(child int, result *CategorisedResult) error) *resultMerger {
+	return &resultMerger{
+		ordering: ordering,
+		push:     push,
+		buffered: make([][]*CategorisedResult, children),
+		finished: make([]bool, children),
+	}
+}
+
+func (m *resultMerger) add(child int, result *CategorisedResult) error {
+	if m.ordering == OrderByChild && child != m.current {
+		m.buffered[child] = append(m.buffered[child], result)
+		return nil
+	}
+	return m.push(child, result)
+}
+
+func (m *resultMerger) finish(child int) error {
+	m.finished[child] = true
+	if m.ordering != OrderByChild {
+		return nil
+	}
+	var firstErr error
+	for m.current < len(m.finished) && m.finished[m.current] {
+		m.current++
+		if m.current == len(m.finished) {
+			break
+		}
+		results := m.buffered[m.current]
+		m.buffered[m.current] = nil
+		for _, result := range results {
+			if err := m.push(m.current, result); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,71 @@
+package scopes_test
+
+import (
+	"fmt"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestRouteChild(c *C) {
+	c.Check(scopes.RouteChild(true, nil, nil), Equals, true)
+	c.Check(scopes.RouteChild(false, nil, nil), Equals, false)
+	c.Check(scopes.RouteChild(true, []string{"music"}, nil), Equals, true)
+	c.Check(scopes.RouteChild(true, []string{"music", "video"}, []string{"video"}), Equals, true)
+	c.Check(scopes.RouteChild(true, []string{"music"}, []string{"video"}), Equals, false)
+	c.Check(scopes.RouteChild(true, nil, []string{"video"}), Equals, false)
+	c.Check(scopes.RouteChild(false, []string{"video"}, []string{"video"}), Equals, false)
+}
+
+// testResults returns distinct results, and a function naming them
+// along with the child they were pushed for.
+func testResults(n int) ([]*scopes.CategorisedResult, func(child int, result *scopes.CategorisedResult) string) {
+	results := make([]*scopes.CategorisedResult, n)
+	names := make(map[*scopes.CategorisedResult]string)
+	for i := range results {
+		results[i] = new(scopes.CategorisedResult)
+		names[results[i]] = fmt.Sprintf("r%d", i)
+	}
+	return results, func(child int, result *scopes.CategorisedResult) string {
+		return fmt.Sprintf("%d:%s", child, names[result])
+	}
+}
+
+func (s *S) TestResultMergerByArrival(c *C) {
+	r, name := testResults(3)
+	var pushed []string
+	m := scopes.NewResultMerger(2, scopes.OrderByArrival, func(child int, result *scopes.CategorisedResult) error {
+		pushed = append(pushed, name(child, result))
+		return nil
+	})
+	c.Check(m.Add(1, r[0]), IsNil)
+	c.Check(m.Add(0, r[1]), IsNil)
+	c.Check(m.Finish(1), IsNil)
+	c.Check(m.Add(0, r[2]), IsNil)
+	c.Check(m.Finish(0), IsNil)
+	c.Check(pushed, DeepEquals, []string{"1:r0", "0:r1", "0:r2"})
+}
+
+func (s *S) TestResultMergerByChild(c *C) {
+	r, name := testResults(4)
+	var pushed []string
+	m := scopes.NewResultMerger(3, scopes.OrderByChild, func(child int, result *scopes.CategorisedResult) error {
+		pushed = append(pushed, name(child, result))
+		return nil
+	})
+	c.Check(m.Add(2, r[0]), IsNil)
+	c.Check(m.Add(1, r[1]), IsNil)
+	c.Check(m.Finish(2), IsNil)
+	c.Check(m.Add(0, r[2]), IsNil)
+	c.Check(pushed, DeepEquals, []string{"0:r2"})
+
+	// Once the first child finishes, the results of the second
+	// are flushed and later ones are pushed directly.
+	c.Check(m.Finish(0), IsNil)
+	c.Check(m.Add(1, r[3]), IsNil)
+	c.Check(pushed, DeepEquals, []string{"0:r2", "1:r1", "1:r3"})
+
+	// The third child has already finished.
+	c.Check(m.Finish(1), IsNil)
+	c.Check(pushed, DeepEquals, []string{"0:r2", "1:r1", "1:r3", "2:r0"})
+}
@@ -0,0 +1,237 @@
+package scopes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// attrField is a struct field mapped to a result attribute with a
+// `scope` tag.
+type attrField struct {
+	name      string
+	omitEmpty bool
+	index     []int
+}
+
+// attrFields returns the fields of a struct type mapped to attributes.
+// Fields of embedded structs without a tag are included as if they
+// belonged to the outer struct.
+func attrFields(t reflect.Type) []attrField {
+	var fields []attrField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("scope")
+		if !ok {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				for _, sub := range attrFields(f.Type) {
+					sub.index = append([]int{i}, sub.index...)
+					fields = append(fields, sub)
+				}
+			}
+			continue
+		}
+		if tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		name, opts := tag, ""
+		if pos := strings.Index(tag, ","); pos >= 0 {
+			name, opts = tag[:pos], tag[pos+1:]
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, attrField{
+			name:      name,
+			omitEmpty: opts == "omitempty",
+			index:     []int{i},
+		})
+	}
+	return fields
+}
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// usesAttrFields reports whether values of the type contain structs
+// mapped with `scope` tags.  Other values are left to encoding/json.
+func usesAttrFields(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return usesAttrFields(t.Elem())
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String && usesAttrFields(t.Elem())
+	case reflect.Struct:
+		ptr := reflect.PtrTo(t)
+		if t.Implements(jsonMarshalerType) || ptr.Implements(jsonMarshalerType) || ptr.Implements(jsonUnmarshalerType) {
+			return false
+		}
+		return len(attrFields(t)) != 0
+	}
+	return false
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// marshalAttributes maps the fields of a struct, or a pointer to one,
+// to result attributes.
+func marshalAttributes(v interface{}) (map[string]interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Can not set result attributes from %T", v)
+	}
+	return structAttributes(rv), nil
+}
+
+func structAttributes(v reflect.Value) map[string]interface{} {
+	attrs := make(map[string]interface{})
+	for _, f := range attrFields(v.Type()) {
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		attrs[f.name] = attrValue(fv)
+	}
+	return attrs
+}
+
+// attrValue converts nested structs mapped with `scope` tags to maps,
+// so that encoding/json encodes them with the attribute names.
+func attrValue(v reflect.Value) interface{} {
+	if !usesAttrFields(v.Type()) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return attrValue(v.Elem())
+	case reflect.Struct:
+		return structAttributes(v)
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		values := make([]interface{}, v.Len())
+		for i := range values {
+			values[i] = attrValue(v.Index(i))
+		}
+		return values
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		values := make(map[string]interface{}, v.Len())
+		for _, key := range v.MapKeys() {
+			values[key.String()] = attrValue(v.MapIndex(key))
+		}
+		return values
+	}
+	return v.Interface()
+}
+
+// decodeAttributes sets the fields of the struct pointed to by v from
+// the JSON encoded result attributes.  Fields whose attribute is
+// missing are left untouched.
+func decodeAttributes(attrs map[string]json.RawMessage, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Can not decode result attributes into %T", v)
+	}
+	return decodeStruct(attrs, rv.Elem())
+}
+
+func decodeStruct(attrs map[string]json.RawMessage, v reflect.Value) error {
+	for _, f := range attrFields(v.Type()) {
+		data, ok := attrs[f.name]
+		if !ok {
+			continue
+		}
+		if err := decodeAttrValue(data, v.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("Can not decode attribute %q: %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+var jsonNull = []byte("null")
+
+// decodeAttrValue is the reverse of attrValue.  The value must be
+// settable.
+func decodeAttrValue(data json.RawMessage, v reflect.Value) error {
+	t := v.Type()
+	if !usesAttrFields(t) {
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+	if bytes.Equal(bytes.TrimSpace(data), jsonNull) {
+		v.Set(reflect.Zero(t))
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return decodeAttrValue(data, v.Elem())
+	case reflect.Struct:
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(data, &attrs); err != nil {
+			return err
+		}
+		return decodeStruct(attrs, v)
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(items), len(items)))
+		} else {
+			v.Set(reflect.Zero(t))
+		}
+		for i := 0; i < len(items) && i < v.Len(); i++ {
+			if err := decodeAttrValue(items[i], v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		m := reflect.MakeMap(t)
+		for key, item := range items {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := decodeAttrValue(item, elem); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		v.Set(m)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package scopes_test
+
+import (
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+type testArtist struct {
+	Name string `scope:"name"`
+	URL  string `scope:"url,omitempty"`
+}
+
+type testCommon struct {
+	URI   string `scope:"uri"`
+	Title string `scope:"title"`
+}
+
+type testTrack struct {
+	testCommon
+	Artists  []testArtist          `scope:"artists,omitempty"`
+	Album    *testArtist           `scope:"album,omitempty"`
+	Ratings  map[string]testArtist `scope:"ratings,omitempty"`
+	Duration int                   `scope:"duration,omitempty"`
+	Tags     []string              `scope:"tags"`
+	Released time.Time             `scope:"released"`
+	Cached   bool                  `scope:"-"`
+	Ignored  string
+	private  string `scope:"private"`
+}
+
+func marshalAttributesJSON(c *C, v interface{}) string {
+	attrs, err := scopes.MarshalAttributes(v)
+	c.Assert(err, IsNil)
+	data, err := json.Marshal(attrs)
+	c.Assert(err, IsNil)
+	return string(data)
+}
+
+func (s *S) TestMarshalAttributes(c *C) {
+	track := testTrack{
+		testCommon: testCommon{URI: "file:///track", Title: "Track"},
+		Artists: []testArtist{
+			{Name: "One", URL: "http://one"},
+			{Name: "Two"},
+		},
+		Tags:     []string{"rock"},
+		Released: time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC),
+		Cached:   true,
+		Ignored:  "ignored",
+		private:  "private",
+	}
+	c.Check(marshalAttributesJSON(c, &track), Equals, `{"artists":[{"name":"One","url":"http://one"},{"name":"Two"}],"released":"2015-01-02T00:00:00Z","tags":["rock"],"title":"Track","uri":"file:///track"}`)
+}
+
+func (s *S) TestMarshalAttributesNested(c *C) {
+	track := testTrack{
+		Album:    &testArtist{Name: "Album"},
+		Ratings:  map[string]testArtist{"best": {Name: "Best"}},
+		Duration: 42,
+	}
+	c.Check(marshalAttributesJSON(c, track), Equals, `{"album":{"name":"Album"},"duration":42,"ratings":{"best":{"name":"Best"}},"released":"0001-01-01T00:00:00Z","tags":null,"title":"","uri":""}`)
+}
+
+func (s *S) TestMarshalAttributesNotStruct(c *C) {
+	_, err := scopes.MarshalAttributes(42)
+	c.Check(err, ErrorMatches, "Can not set result attributes from int")
+	_, err = scopes.MarshalAttributes((*testTrack)(nil))
+	c.Check(err, ErrorMatches, `Can not set result attributes from \*scopes_test.testTrack`)
+}
+
+func (s *S) TestDecodeAttributes(c *C) {
+	track := testTrack{Cached: true, Duration: 10}
+	err := scopes.DecodeAttributes(`{
+		"uri": "file:///track",
+		"title": "Track",
+		"artists": [{"name": "One", "url": "http://one"}, {"name": "Two"}],
+		"album": {"name": "Album"},
+		"ratings": {"best": {"name": "Best"}},
+		"tags": ["rock"],
+		"released": "2015-01-02T00:00:00Z",
+		"private": "private",
+		"Ignored": "ignored"
+	}`, &track)
+	c.Assert(err, IsNil)
+	c.Check(track, DeepEquals, testTrack{
+		testCommon: testCommon{URI: "file:///track", Title: "Track"},
+		Artists: []testArtist{
+			{Name: "One", URL: "http://one"},
+			{Name: "Two"},
+		},
+		Album:    &testArtist{Name: "Album"},
+		Ratings:  map[string]testArtist{"best": {Name: "Best"}},
+		Duration: 10,
+		Tags:     []string{"rock"},
+		Released: time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC),
+		Cached:   true,
+	})
+}
+
+func (s *S) TestDecodeAttributesNull(c *C) {
+	track := testTrack{
+		Album:   &testArtist{Name: "Album"},
+		Artists: []testArtist{{Name: "One"}},
+	}
+	err := scopes.DecodeAttributes(`{"album": null, "artists": null}`, &track)
+	c.Assert(err, IsNil)
+	c.Check(track.Album, IsNil)
+	c.Check(track.Artists, IsNil)
+}
+
+func (s *S) TestDecodeAttributesErrors(c *C) {
+	var track testTrack
+	err := scopes.DecodeAttributes(`{"artists": [{"name": 42}]}`, &track)
+	c.Check(err, ErrorMatches, `Can not decode attribute "artists": Can not decode attribute "name": .*`)
+
+	err = scopes.DecodeAttributes(`{}`, track)
+	c.Check(err, ErrorMatches, "Can not decode result attributes into scopes_test.testTrack")
+}
+
+func (s *S) TestAttributesRoundTrip(c *C) {
+	track := testTrack{
+		testCommon: testCommon{URI: "file:///track", Title: "Track"},
+		Artists:    []testArtist{{Name: "One"}},
+		Album:      &testArtist{Name: "Album", URL: "http://album"},
+		Tags:       []string{},
+		Released:   time.Date(2015, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	var decoded testTrack
+	c.Assert(scopes.DecodeAttributes(marshalAttributesJSON(c, track), &decoded), IsNil)
+	c.Check(decoded, DeepEquals, track)
+}
@@ -0,0 +1,85 @@
+package scopes
+
+import (
+	"errors"
+)
+
+// ErrCardinalityReached is returned when pushing a result to a reply
+// that has already received as many results as the client asked for.
+var ErrCardinalityReached = errors.New("search cardinality reached")
+
+// pushStopped reports whether err is one of the errors Push returns
+// to tell a scope to stop producing results.  A scope returning them
+// from Search or Preview has completed normally.
+func pushStopped(err error) bool {
+	switch err {
+	case ErrCardinalityReached, ErrQueryCancelled, ErrReplyFinished:
+		return true
+	default:
+		return false
+	}
+}
+
+// pushCounter tracks the results pushed to a search reply against the
+// cardinality requested by the client, and against the optional
+// limits of individual categories.
+type pushCounter struct {
+	cardinality    int
+	pushed         int
+	categoryLimits map[string]int
+	categoryPushed map[string]int
+}
+
+// hasCategoryLimits reports whether the category of each result must
+// be passed to reserve.
+func (c *pushCounter) hasCategoryLimits() bool {
+	return len(c.categoryLimits) != 0
+}
+
+func (c *pushCounter) setCategoryLimit(categoryId string, limit int) {
+	if c.categoryLimits == nil {
+		c.categoryLimits = make(map[string]int)
+		c.categoryPushed = make(map[string]int)
+	}
+	if limit <= 0 {
+		delete(c.categoryLimits, categoryId)
+	} else {
+		c.categoryLimits[categoryId] = limit
+	}
+}
+
+// reserve counts a result about to be pushed, or returns
+// ErrCardinalityReached if it would exceed a limit.
+func (c *pushCounter) reserve(categoryId string) error {
+	if c.cardinality > 0 && c.pushed >= c.cardinality {
+		return ErrCardinalityReached
+	}
+	if limit, ok := c.categoryLimits[categoryId]; ok && c.categoryPushed[categoryId] >= limit {
+		return ErrCardinalityReached
+	}
+	c.pushed++
+	if c.categoryPushed != nil {
+		c.categoryPushed[categoryId]++
+	}
+	return nil
+}
+
+// release forgets a result reserved but not pushed.
+func (c *pushCounter) release(categoryId string) {
+	c.pushed--
+	if c.categoryPushed != nil {
+		c.categoryPushed[categoryId]--
+	}
+}
+
+// remaining returns the number of results that may still be pushed,
+// or -1 if the client did not set a limit.
+func (c *pushCounter) remaining() int {
+	if c.cardinality <= 0 {
+		return -1
+	}
+	if c.pushed >= c.cardinality {
+		return 0
+	}
+	return c.cardinality - c.pushed
+}
@@ -0,0 +1,66 @@
+package scopes_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestPushCounterCardinality(c *C) {
+	counter := scopes.NewPushCounter(2)
+	c.Check(counter.Remaining(), Equals, 2)
+	c.Check(counter.Reserve(""), IsNil)
+	c.Check(counter.Remaining(), Equals, 1)
+	c.Check(counter.Reserve(""), IsNil)
+	c.Check(counter.Remaining(), Equals, 0)
+	c.Check(counter.Reserve(""), Equals, scopes.ErrCardinalityReached)
+
+	// A result that could not be pushed frees its slot.
+	counter.Release("")
+	c.Check(counter.Remaining(), Equals, 1)
+	c.Check(counter.Reserve(""), IsNil)
+}
+
+func (s *S) TestPushCounterUnlimited(c *C) {
+	counter := scopes.NewPushCounter(0)
+	c.Check(counter.Remaining(), Equals, -1)
+	for i := 0; i < 100; i++ {
+		c.Check(counter.Reserve(""), IsNil)
+	}
+	c.Check(counter.Remaining(), Equals, -1)
+}
+
+func (s *S) TestPushCounterCategoryLimits(c *C) {
+	counter := scopes.NewPushCounter(10)
+	counter.SetCategoryLimit("featured", 1)
+	c.Check(counter.Reserve("featured"), IsNil)
+	c.Check(counter.Reserve("featured"), Equals, scopes.ErrCardinalityReached)
+	c.Check(counter.Reserve("other"), IsNil)
+	c.Check(counter.Remaining(), Equals, 8)
+
+	// Removing the limit allows further results.
+	counter.SetCategoryLimit("featured", 0)
+	c.Check(counter.Reserve("featured"), IsNil)
+}
+
+func (s *S) TestCompleteReply(c *C) {
+	reply := newRecordingReply()
+	scopes.CompleteReply(reply, nil)
+	c.Check(len(reply.finished), Equals, 1)
+
+	// A Search returning the error that told it to stop pushing
+	// finishes normally.
+	for _, err := range []error{scopes.ErrCardinalityReached, scopes.ErrQueryCancelled, scopes.ErrReplyFinished} {
+		reply := newRecordingReply()
+		scopes.CompleteReply(reply, err)
+		c.Check(len(reply.finished), Equals, 1)
+		c.Check(len(reply.errors), Equals, 0)
+	}
+
+	reply = newRecordingReply()
+	failure := errors.New("backend failed")
+	scopes.CompleteReply(reply, failure)
+	c.Check(len(reply.finished), Equals, 0)
+	c.Check(<-reply.errors, Equals, failure)
+}
@@ -0,0 +1,64 @@
+#include <cstring>
+#include <stdexcept>
+
+#include <unity/scopes/CannedQuery.h>
+#include <unity/scopes/Category.h>
+#include <unity/scopes/CategoryRenderer.h>
+#include <unity/scopes/testing/Category.h>
+
+extern "C" {
+#include "_cgo_export.h"
+}
+#include "helpers.h"
+#include "smartptr_helper.h"
+
+using namespace unity::scopes;
+using namespace gounityscopes::internal;
+
+/* Category objects */
+char *category_get_id(SharedPtrData cat) {
+    return strdup(get_ptr<const Category>(cat)->id().c_str());
+}
+
+char *category_get_title(SharedPtrData cat) {
+    return strdup(get_ptr<const Category>(cat)->title().c_str());
+}
+
+char *category_get_icon(SharedPtrData cat) {
+    return strdup(get_ptr<const Category>(cat)->icon().c_str());
+}
+
+char *category_get_renderer_template(SharedPtrData cat) {
+    return strdup(get_ptr<const Category>(cat)->renderer_template().data().c_str());
+}
+
+_CannedQuery *category_get_query(SharedPtrData cat) {
+    auto query = get_ptr<const Category>(cat)->query();
+    if (!query) {
+        return nullptr;
+    }
+    return reinterpret_cast<_CannedQuery*>(new CannedQuery(*query));
+}
+
+// new_unregistered_category creates a category that is not known to any
+// reply.  The public constructor of testing::Category is the only way
+// to do so.
+void new_unregistered_category(const StrData id, const StrData title, const StrData icon, _CannedQuery *query, const StrData cat_template, SharedPtrData category, char **error) {
+    try {
+        CategoryRenderer renderer;
+        std::string renderer_template = from_gostring(cat_template);
+        if (!renderer_template.empty()) {
+            renderer = CategoryRenderer(renderer_template);
+        }
+        CannedQuery::SCPtr category_query;
+        if (query != nullptr) {
+            category_query.reset(new CannedQuery(*reinterpret_cast<CannedQuery*>(query)));
+        }
+        std::shared_ptr<const Category> cat(new testing::Category(
+            from_gostring(id), from_gostring(title), from_gostring(icon),
+            category_query, renderer));
+        init_ptr<const Category>(category, cat);
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+    }
+}
@@ -0,0 +1,65 @@
+package scopes
+
+// #include <stdlib.h>
+// #include "shim.h"
+import "C"
+import (
+	"runtime"
+	"unsafe"
+)
+
+// Id returns the identifier of the category.
+func (cat *Category) Id() string {
+	s := C.category_get_id(&cat.c[0])
+	defer C.free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+// Title returns the title of the category.
+func (cat *Category) Title() string {
+	s := C.category_get_title(&cat.c[0])
+	defer C.free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+// Icon returns the icon of the category.
+func (cat *Category) Icon() string {
+	s := C.category_get_icon(&cat.c[0])
+	defer C.free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+// RendererTemplate returns the JSON template used to display the
+// results of the category.
+func (cat *Category) RendererTemplate() string {
+	s := C.category_get_renderer_template(&cat.c[0])
+	defer C.free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+// Query returns the query run to show all of the category's results,
+// or nil if the category was registered without one.
+func (cat *Category) Query() *CannedQuery {
+	q := C.category_get_query(&cat.c[0])
+	if q == nil {
+		return nil
+	}
+	return makeCannedQuery(q)
+}
+
+// newUnregisteredCategory creates a category without registering it
+// with a reply.  The query may be nil.
+func newUnregisteredCategory(id, title, icon string, query *CannedQuery, template string) (*Category, error) {
+	var q *C._CannedQuery
+	if query != nil {
+		q = query.q
+	}
+	cat := new(Category)
+	var errorString *C.char
+	C.new_unregistered_category(strData(id), strData(title), strData(icon), q, strData(template), &cat.c[0], &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	runtime.SetFinalizer(cat, finalizeCategory)
+	return cat, nil
+}
@@ -0,0 +1,47 @@
+package scopes_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+const testCategoryTemplate = `{"schema-version":1,"template":{"category-layout":"carousel"},"components":{"title":"name"}}`
+
+func (s *S) TestCategory(c *C) {
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "icon.png", nil, testCategoryTemplate)
+	c.Assert(err, IsNil)
+	c.Check(cat.Id(), Equals, "cat1")
+	c.Check(cat.Title(), Equals, "Category 1")
+	c.Check(cat.Icon(), Equals, "icon.png")
+	c.Check(cat.Query(), IsNil)
+
+	var template, expected interface{}
+	c.Assert(json.Unmarshal([]byte(cat.RendererTemplate()), &template), IsNil)
+	c.Assert(json.Unmarshal([]byte(testCategoryTemplate), &expected), IsNil)
+	c.Check(template, DeepEquals, expected)
+}
+
+func (s *S) TestCategoryWithQuery(c *C) {
+	query := scopes.NewCannedQuery("scope", "query", "dept")
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", query, "")
+	c.Assert(err, IsNil)
+	c.Assert(cat.Query(), NotNil)
+	c.Check(cat.Query().ScopeID(), Equals, "scope")
+	c.Check(cat.Query().QueryString(), Equals, "query")
+	c.Check(cat.Query().DepartmentID(), Equals, "dept")
+}
+
+func (s *S) TestCategoryInvalidTemplate(c *C) {
+	_, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, "not JSON")
+	c.Check(err, NotNil)
+}
+
+func (s *S) TestRegisterCategoryWithQueryFinished(c *C) {
+	reply := scopes.NewFinishedSearchReply()
+	query := scopes.NewCannedQuery("scope", "query", "dept")
+	cat, err := reply.RegisterCategoryWithQuery("cat1", "Category 1", "", "", query)
+	c.Check(err, Equals, scopes.ErrReplyFinished)
+	c.Check(cat, IsNil)
+}
@@ -2,6 +2,7 @@
 
 #include <unity/scopes/ChildScope.h>
 #include <unity/scopes/ScopeMetadata.h>
+#include <unity/scopes/Variant.h>
 
 extern "C" {
 #include "_cgo_export.h"
@@ -30,6 +31,21 @@ char *child_scope_get_id(_ChildScope *childscope) {
     return strdup(reinterpret_cast<ChildScope*>(childscope)->id.c_str());
 }
 
+int child_scope_get_enabled(_ChildScope *childscope) {
+    return reinterpret_cast<ChildScope*>(childscope)->enabled;
+}
+
+void *child_scope_get_keywords(_ChildScope *childscope, int *length) {
+    auto &keywords = reinterpret_cast<ChildScope*>(childscope)->keywords;
+    VariantArray array(keywords.begin(), keywords.end());
+    return as_bytes(Variant(array).serialize_json(), length);
+}
+
+_ScopeMetadata *child_scope_get_metadata(_ChildScope *childscope) {
+    return reinterpret_cast<_ScopeMetadata*>(
+        new ScopeMetadata(reinterpret_cast<ChildScope*>(childscope)->metadata));
+}
+
 void set_child_scopes_list(void *child_scopes_list, _ChildScope **source_child_scopes, int length) {
     ChildScopeList *c_child_scopes_list = reinterpret_cast<ChildScopeList*>(child_scopes_list);
     for (int i=0; i < length; ++i) {
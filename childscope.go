@@ -4,6 +4,7 @@ package scopes
 // #include "shim.h"
 import "C"
 import (
+	"encoding/json"
 	"runtime"
 	"unsafe"
 )
@@ -46,3 +47,29 @@ func (childscope *ChildScope) Id() string {
 	defer C.free(unsafe.Pointer(s))
 	return C.GoString(s)
 }
+
+// Enabled returns true if the child scope is enabled in the
+// aggregator's settings.
+func (childscope *ChildScope) Enabled() bool {
+	return C.child_scope_get_enabled(childscope.c) != 0
+}
+
+// Keywords returns the keywords the child scope is aggregated by.
+func (childscope *ChildScope) Keywords() []string {
+	var length C.int
+	keywordData := C.child_scope_get_keywords(childscope.c, &length)
+	defer C.free(keywordData)
+	var keywords []string
+	if err := json.Unmarshal(C.GoBytes(keywordData, length), &keywords); err != nil {
+		panic(err)
+	}
+	return keywords
+}
+
+// Metadata returns the metadata of the child scope.
+func (childscope *ChildScope) Metadata() *ScopeMetadata {
+	m := C.child_scope_get_metadata(childscope.c)
+	jsonData := C.get_scope_metadata_serialized(m)
+	defer C.free(unsafe.Pointer(jsonData))
+	return makeScopeMetadata(m, C.GoString(jsonData))
+}
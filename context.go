@@ -0,0 +1,89 @@
+package scopes
+
+import (
+	"context"
+	"time"
+)
+
+// ContextScope is an interface that may be implemented by scopes that
+// prefer to receive a context.Context rather than a cancellation
+// channel.  It is run with RunContext, or with RunWithOptions in
+// place of a Scope.
+//
+// When a scope implements ContextScope, SearchContext and
+// PreviewContext are called in place of Search and Preview, which it
+// need not implement.  The context is cancelled when the client
+// cancels the query, carries a deadline if a query timeout has been
+// configured, and holds the query and its metadata as values.
+type ContextScope interface {
+	SetScopeBase(base *ScopeBase)
+	SearchContext(ctx context.Context, query *CannedQuery, metadata *SearchMetadata, reply *SearchReply) error
+	PreviewContext(ctx context.Context, result *Result, metadata *ActionMetadata, reply *PreviewReply) error
+}
+
+// ContextActivator is the context aware variant of Activator.  It is
+// used in preference to Activate when implemented.
+type ContextActivator interface {
+	ActivateContext(ctx context.Context, result *Result, metadata *ActionMetadata) (*ActivationResponse, error)
+}
+
+// ContextPerformActioner is the context aware variant of
+// PerformActioner.  It is used in preference to PerformAction when
+// implemented.
+type ContextPerformActioner interface {
+	PerformActionContext(ctx context.Context, result *Result, metadata *ActionMetadata, widgetId, actionId string) (*ActivationResponse, error)
+}
+
+type contextKey int
+
+const (
+	cannedQueryKey contextKey = iota
+	searchMetadataKey
+	actionMetadataKey
+)
+
+// CannedQueryFromContext returns the search query stored in a context
+// passed to SearchContext.
+func CannedQueryFromContext(ctx context.Context) (*CannedQuery, bool) {
+	query, ok := ctx.Value(cannedQueryKey).(*CannedQuery)
+	return query, ok
+}
+
+// SearchMetadataFromContext returns the search metadata stored in a
+// context passed to SearchContext.
+func SearchMetadataFromContext(ctx context.Context) (*SearchMetadata, bool) {
+	metadata, ok := ctx.Value(searchMetadataKey).(*SearchMetadata)
+	return metadata, ok
+}
+
+// ActionMetadataFromContext returns the action metadata stored in a
+// context passed to PreviewContext, ActivateContext or
+// PerformActionContext.
+func ActionMetadataFromContext(ctx context.Context) (*ActionMetadata, bool) {
+	metadata, ok := ctx.Value(actionMetadataKey).(*ActionMetadata)
+	return metadata, ok
+}
+
+// newQueryContext creates a context that is cancelled when the query
+// is cancelled through the given channel, or when the timeout
+// expires.  The returned cancel function must be called once the
+// query completes.
+func newQueryContext(cancelled <-chan bool, timeout time.Duration) (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	go func() {
+		select {
+		case <-cancelled:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,77 @@
+package scopes_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestQueryContextCancelled(c *C) {
+	cancelled := make(chan bool, 1)
+	ctx, cancel := scopes.NewQueryContext(cancelled, 0)
+	defer cancel()
+
+	_, hasDeadline := ctx.Deadline()
+	c.Check(hasDeadline, Equals, false)
+	c.Check(ctx.Err(), IsNil)
+
+	cancelled <- true
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		c.Fatal("context was not cancelled")
+	}
+	c.Check(ctx.Err(), Equals, context.Canceled)
+}
+
+func (s *S) TestQueryContextTimeout(c *C) {
+	ctx, cancel := scopes.NewQueryContext(nil, 10*time.Millisecond)
+	defer cancel()
+
+	_, hasDeadline := ctx.Deadline()
+	c.Check(hasDeadline, Equals, true)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		c.Fatal("context did not time out")
+	}
+	c.Check(ctx.Err(), Equals, context.DeadlineExceeded)
+}
+
+func (s *S) TestQueryContextValues(c *C) {
+	_, ok := scopes.CannedQueryFromContext(context.Background())
+	c.Check(ok, Equals, false)
+	_, ok = scopes.SearchMetadataFromContext(context.Background())
+	c.Check(ok, Equals, false)
+	_, ok = scopes.ActionMetadataFromContext(context.Background())
+	c.Check(ok, Equals, false)
+}
+
+// contextScope implements ContextScope without the methods of Scope.
+type contextScope struct {
+	startedId string
+}
+
+func (scope *contextScope) SetScopeBase(base *scopes.ScopeBase) {}
+
+func (scope *contextScope) SearchContext(ctx context.Context, query *scopes.CannedQuery, metadata *scopes.SearchMetadata, reply *scopes.SearchReply) error {
+	return nil
+}
+
+func (scope *contextScope) PreviewContext(ctx context.Context, result *scopes.Result, metadata *scopes.ActionMetadata, reply *scopes.PreviewReply) error {
+	return nil
+}
+
+func (scope *contextScope) Start(scopeId string) error {
+	scope.startedId = scopeId
+	return nil
+}
+
+func (s *S) TestContextScopeStart(c *C) {
+	scope := &contextScope{}
+	var _ scopes.ContextScope = scope
+	c.Check(scopes.CallScopeStart(scope, "myscope"), IsNil)
+	c.Check(scope.startedId, Equals, "myscope")
+}
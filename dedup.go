@@ -0,0 +1,114 @@
+package scopes
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DedupMode selects which results a SearchReply compares when dropping
+// duplicates.
+type DedupMode int
+
+const (
+	// DedupOff pushes every result.  This is the default.
+	DedupOff DedupMode = iota
+
+	// DedupPerCategory drops results whose key has already been
+	// pushed to the same category.
+	DedupPerCategory
+
+	// DedupAcrossReply drops results whose key has already been
+	// pushed to any category of the reply.
+	DedupAcrossReply
+)
+
+// SetDeduplication enables dropping repeated results, as commonly
+// happens when merging the results of several backends.  Results are
+// identified by the string returned by key, or by their URI if key is
+// nil.  Results with an empty key are never dropped.
+//
+// A duplicate is silently discarded: Push returns nil without sending
+// it, and it does not count towards the cardinality of the reply.
+// The key function is called without holding the reply's lock, so it
+// may run concurrently when several goroutines push results.
+//
+// There is no mode merging duplicates into the result already pushed:
+// the scopes runtime sends each result to the client as it is pushed,
+// and offers no way to amend it afterwards.  Scopes wishing to merge
+// duplicates, for example by combining the attributes reported by
+// several backends, must gather the results and merge them before
+// pushing.
+func (reply *SearchReply) SetDeduplication(mode DedupMode, key func(result *CategorisedResult) string) {
+	if key == nil {
+		key = DedupByURI
+	}
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if mode == DedupOff {
+		reply.dedup = nil
+		return
+	}
+	reply.dedup = newResultDeduper(mode, key)
+}
+
+// DedupByAttributes returns a key function identifying results by the
+// values of the named attributes, for use with SetDeduplication or as
+// the DedupKey of an Aggregator.  Results lacking all of the
+// attributes have an empty key.
+func DedupByAttributes(attrs ...string) func(result *CategorisedResult) string {
+	return func(result *CategorisedResult) string {
+		values := make([]string, len(attrs))
+		found := false
+		for i, attr := range attrs {
+			var value json.RawMessage
+			if err := result.Get(attr, &value); err == nil {
+				values[i] = string(value)
+				found = true
+			}
+		}
+		if !found {
+			return ""
+		}
+		return strings.Join(values, "\x00")
+	}
+}
+
+// resultDeduper records the keys of the results pushed to a reply.
+type resultDeduper struct {
+	perCategory bool
+	key         func(result *CategorisedResult) string
+	seen        map[string]bool
+}
+
+func newResultDeduper(mode DedupMode, key func(result *CategorisedResult) string) *resultDeduper {
+	return &resultDeduper{
+		perCategory: mode == DedupPerCategory,
+		key:         key,
+		seen:        make(map[string]bool),
+	}
+}
+
+// add records the key of a result about to be pushed to the given
+// category.  It returns the recorded key, and false if the result is a
+// duplicate.
+func (d *resultDeduper) add(categoryId, key string) (string, bool) {
+	if key == "" {
+		return "", true
+	}
+	if d.perCategory {
+		key = categoryId + "\x00" + key
+	}
+	if d.seen[key] {
+		return key, false
+	}
+	d.seen[key] = true
+	return key, true
+}
+
+// remove forgets a key recorded by add for a result that was not
+// pushed.
+func (d *resultDeduper) remove(key string) {
+	if key != "" {
+		delete(d.seen, key)
+	}
+}
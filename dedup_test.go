@@ -0,0 +1,47 @@
+package scopes_test
+
+import (
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestResultDeduperAcrossReply(c *C) {
+	d := scopes.NewResultDeduper(scopes.DedupAcrossReply)
+	_, ok := d.Add("cat1", "uri1")
+	c.Check(ok, Equals, true)
+	_, ok = d.Add("cat1", "uri1")
+	c.Check(ok, Equals, false)
+	_, ok = d.Add("cat2", "uri1")
+	c.Check(ok, Equals, false)
+	_, ok = d.Add("cat1", "uri2")
+	c.Check(ok, Equals, true)
+}
+
+func (s *S) TestResultDeduperPerCategory(c *C) {
+	d := scopes.NewResultDeduper(scopes.DedupPerCategory)
+	_, ok := d.Add("cat1", "uri1")
+	c.Check(ok, Equals, true)
+	_, ok = d.Add("cat2", "uri1")
+	c.Check(ok, Equals, true)
+	_, ok = d.Add("cat2", "uri1")
+	c.Check(ok, Equals, false)
+}
+
+func (s *S) TestResultDeduperEmptyKey(c *C) {
+	d := scopes.NewResultDeduper(scopes.DedupAcrossReply)
+	_, ok := d.Add("cat1", "")
+	c.Check(ok, Equals, true)
+	_, ok = d.Add("cat1", "")
+	c.Check(ok, Equals, true)
+}
+
+func (s *S) TestResultDeduperRemove(c *C) {
+	d := scopes.NewResultDeduper(scopes.DedupPerCategory)
+	key, ok := d.Add("cat1", "uri1")
+	c.Check(ok, Equals, true)
+
+	// A result that could not be pushed may be pushed again.
+	d.Remove(key)
+	_, ok = d.Add("cat1", "uri1")
+	c.Check(ok, Equals, true)
+}
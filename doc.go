@@ -28,15 +28,54 @@ Search method to be invoked:
 
 In general, scopes will:
 
-* Register result categories via reply.RegisterCategory()
+* Register result categories via reply.RegisterCategory(), or
+reply.RegisterCategoryWithRenderer() to describe their layout with a
+CategoryRenderer rather than a JSON template
 
 * Create new results via NewCategorisedResult(), and push them with reply.Push(result)
 
+* Stop producing results once reply.Push(result) returns
+ErrCardinalityReached or ErrQueryCancelled
+
 * Check for cancellation requests via the provided channel.
 
+The reply may be used from several goroutines at once.  Once Search
+returns, the reply is finished automatically, and further calls to
+reply.Push() return ErrReplyFinished.
+
+During development, reply.SetResultValidation(scopes.ValidationLog)
+reports results lacking the attributes their category's template
+displays.
+
+Diagnostics can be written through reply.Logger(), whose entries are
+tagged with the scope ID, a per-query ID, the query string, department
+and form factor, so that lines from concurrent queries can be told
+apart:
+
+    reply.Logger().Warningf("backend returned %d", resp.StatusCode)
+
+Entries are written to standard error by default.  SetLogSinks selects
+other destinations, such as a NewFileLogSink in the cache directory or
+a NewKeyValueLogSink for journald style key=value output.
+
 The Search method will be invoked with an empty query when surfacing
 results are wanted.
 
+When results are missing for a reason the user should know about,
+such as a lack of internet connectivity, the scope can tell the client
+with reply.Info(scopes.InfoNoInternet, "").
+
+Scopes that would rather receive a context.Context than a cancellation
+channel can implement the ContextScope interface instead of Scope.  Its
+SearchContext and PreviewContext methods are called in place of Search
+and Preview, and the scope is run with RunContext rather than Run:
+
+    func (s *MyScope) SearchContext(ctx context.Context, query *scopes.CannedQuery, metadata *scopes.SearchMetadata, reply *scopes.SearchReply) error {
+        req, _ := http.NewRequest("GET", backendURL, nil)
+        resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+        ...
+    }
+
 The shell may ask the scope to provide a preview of a result, which causes the Preview method to be invoked:
 
     func (s *MyScope) Preview(result *scopes.Result, metadata *scopes.ActionMetadata, reply *scopes.PreviewReply, cancelled <-chan bool) error {
@@ -62,6 +101,55 @@ respond to, the scope should implement the PerformAction method:
 The PerformAction method is not part of the main Scope interface, so
 the feature need only be implemented for scopes that use the feature.
 
+Scopes that need to set up resources when the runtime starts them, or
+release them on shutdown, can implement the Start and Stop methods:
+
+    func (s *MyScope) Start(scopeId string) error {
+        // open database handles, warm caches, etc.
+        return nil
+    }
+
+    func (s *MyScope) Stop() error {
+        // flush state and close handles
+        return nil
+    }
+
+An error returned by Start prevents the scope from starting.
+
+Aggregator scopes can query their children with ScopeBase.SubSearch.
+The child's results, categories, departments and filters are delivered
+on a channel, and the subsearch is cancelled along with the parent
+query:
+
+    search, err := s.base.SubSearch(child.Id(), query, metadata, cancelled)
+    if err != nil {
+        return err
+    }
+    for event := range search.Events() {
+        if event.Result != nil {
+            ...
+        }
+    }
+    return search.Err()
+
+The Aggregator type implements this fan-out for the common case: it
+queries the enabled children whose keywords match the query in
+parallel, bounds each with a timeout, and pushes their results into
+the aggregator's categories, optionally de-duplicated:
+
+    aggregator := &scopes.Aggregator{
+        Base:         base,
+        ChildTimeout: 3 * time.Second,
+        Ordering:     scopes.OrderByChild,
+        DedupKey:     scopes.DedupByURI,
+    }
+
+A result of a child can be wrapped in a result of the aggregator with
+StoreResult.  Previews and activations of the wrapping result are then
+sent to the child, unless the aggregator intercepts them, in which case
+it can still forward them with Result.ForwardPreview and
+Result.ForwardActivation.
+
 Finally, the scope can be exported in the main function:
 
     func main() {
@@ -70,6 +158,20 @@ Finally, the scope can be exported in the main function:
         }
     }
 
+Run reads the configuration file locations from the --runtime and
+--scope command line flags.  Programs with their own command line
+handling can pass them explicitly with RunWithOptions:
+
+    err := scopes.RunWithOptions(&MyScope{}, scopes.Options{
+        RuntimeConfig: runtimeConfig,
+        ScopeConfig:   scopeConfig,
+    })
+
+When the scope is stopped, either by the runtime or by calling
+Shutdown, queries still in progress are cancelled and Run waits for
+them to return before returning itself.  It gives up after a grace
+period, which can be changed with SetShutdownGracePeriod.
+
 The scope executable can be deployed to a scope directory named like:
 
     /usr/lib/${arch}/unity-scopes/${scope_name}
@@ -83,5 +185,33 @@ should look something like:
     Description = Long description of scope
     Author =
     ScopeRunner = ${scope_executable} --runtime %R --scope %S
+
+Queries can be bounded by adding timeouts, written as Go durations, to
+a GoScope group in the same file.  Once a timeout expires, the query
+is cancelled and an error is reported to the client:
+
+    [GoScope]
+    SearchTimeout = 10s
+    PreviewTimeout = 5s
+    ActivateTimeout = 2s
+    PerformActionTimeout = 2s
+
+These defaults can be overridden with SetQueryTimeouts.
+
+The number of Search and Preview calls running at once can be bounded
+with SetQueryLimits, so that a burst of queries while the user types
+does not overwhelm the scope's backend.  Queries over the limit wait
+in a queue, and the CancelOldestPending policy drops queries that were
+superseded before they got to run:
+
+    scopes.SetQueryLimits(scopes.QueryLimits{
+        MaxSearches: 2,
+        Policy:      scopes.CancelOldestPending,
+    })
+
+Counters and latency histograms for the queries handled by the scope
+are available through Metrics, and are published with the expvar
+package.  Setting Options.MetricsInterval additionally writes them
+periodically to metrics.json in the scope's cache directory.
 */
 package scopes
@@ -1,7 +1,10 @@
 package scopes
 
 import (
+	"context"
 	"encoding/json"
+	"sync"
+	"time"
 )
 
 // This file exports certain private functions for use by tests.
@@ -10,6 +13,10 @@ func NewTestingResult() *Result {
 	return newTestingResult()
 }
 
+func NewTestingCategory(id, title, icon string, query *CannedQuery, template string) (*Category, error) {
+	return newUnregisteredCategory(id, title, icon, query, template)
+}
+
 func NewTestingScopeMetadata(json_data string) ScopeMetadata {
 	var scopeMetadata ScopeMetadata
 	if err := json.Unmarshal([]byte(json_data), &scopeMetadata); err != nil {
@@ -18,3 +25,309 @@ func NewTestingScopeMetadata(json_data string) ScopeMetadata {
 
 	return scopeMetadata
 }
+
+func NewQueryContext(cancelled <-chan bool, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return newQueryContext(cancelled, timeout)
+}
+
+func MakeCancelChannel() chan bool {
+	return makeCancelChannel()
+}
+
+func SendCancelChannel(ch chan bool) {
+	sendCancelChannel(ch)
+}
+
+func ReleaseCancelChannel(ch chan bool) {
+	releaseCancelChannel(ch)
+}
+
+func IsCancelled(ch <-chan bool) bool {
+	return isCancelled(ch)
+}
+
+func CallProtected(f func() error) error {
+	return callProtected(f)
+}
+
+func CallScopeStart(scope ScopeBaseSetter, scopeId string) error {
+	return testingCallScopeStart(scope, scopeId)
+}
+
+func CallScopeStop(scope ScopeBaseSetter) error {
+	return testingCallScopeStop(scope)
+}
+
+func CheckOptions(options Options) (string, error) {
+	return checkOptions(options)
+}
+
+func AcceptQueries() {
+	acceptQueries()
+}
+
+func RequestShutdown() {
+	requestShutdown()
+}
+
+func ClearShutdown() {
+	clearShutdown()
+}
+
+func StartQuery(ch chan bool) bool {
+	return startQuery(ch)
+}
+
+func FinishQuery(ch chan bool) {
+	finishQuery(ch)
+}
+
+func DrainQueries(gracePeriod time.Duration) {
+	drainQueries(gracePeriod)
+}
+
+func ReadQueryTimeouts(filename string) (QueryTimeouts, error) {
+	return readQueryTimeouts(filename)
+}
+
+func SetConfigTimeouts(timeouts QueryTimeouts) {
+	setConfigTimeouts(timeouts)
+}
+
+func CurrentQueryTimeouts() QueryTimeouts {
+	return currentQueryTimeouts()
+}
+
+func CallWithTimeout(timeout time.Duration, f func(ctx context.Context) error) error {
+	return callWithTimeout(timeout, f)
+}
+
+func NewQueryTimer(timeout time.Duration, cancel chan bool, reply interface {
+	Finished()
+	Error(err error)
+}) *time.Timer {
+	return newQueryTimer(timeout, cancel, reply)
+}
+
+func CompleteReply(reply interface {
+	Finished()
+	Error(err error)
+}, err error) {
+	completeReply(reply, err)
+}
+
+func StopQueryTimer(timer *time.Timer) bool {
+	return stopQueryTimer(timer)
+}
+
+func NewQueryLimiter(max, maxPending int, policy QueryLimitPolicy) (acquire func(chan bool) error, release func()) {
+	l := new(queryLimiter)
+	l.configure(max, maxPending, policy)
+	return l.acquire, l.release
+}
+
+func RecordSearch(latency time.Duration, err error, cancelled <-chan bool, timedOut bool) {
+	queryStarted(&metrics.Search)
+	queryFinished(&metrics.Search, latency, outcomeOf(err, cancelled, timedOut))
+}
+
+func ResultsPushed(n int) {
+	resultsPushed(n)
+}
+
+func ResetMetrics() {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	metrics = MetricsSnapshot{}
+}
+
+func WriteMetricsFile(dir string) error {
+	return writeMetricsFile(dir)
+}
+
+func Logf(format string, v ...interface{}) {
+	logf(format, v...)
+}
+
+func NewQueryLogger(fields ...LogField) *Logger {
+	return newQueryLogger(fields...)
+}
+
+func NewTestingSubSearch() *SubSearch {
+	return newSubSearch()
+}
+
+func (search *SubSearch) TestingPush(event *SubSearchEvent) {
+	search.push(event)
+}
+
+func (search *SubSearch) TestingFinish(err error) {
+	search.finish(err)
+}
+
+func RouteChild(enabled bool, childKeywords, queryKeywords []string) bool {
+	return routeChild(enabled, childKeywords, queryKeywords)
+}
+
+type ResultMerger struct {
+	m *resultMerger
+}
+
+func NewResultMerger(children int, ordering AggregatorOrdering, push func(child int, result *CategorisedResult) error) ResultMerger {
+	return ResultMerger{newResultMerger(children, ordering, push)}
+}
+
+func (m ResultMerger) Add(child int, result *CategorisedResult) error {
+	return m.m.add(child, result)
+}
+
+func (m ResultMerger) Finish(child int) error {
+	return m.m.finish(child)
+}
+
+type PushCounter struct {
+	c pushCounter
+}
+
+func NewPushCounter(cardinality int) *PushCounter {
+	return &PushCounter{pushCounter{cardinality: cardinality}}
+}
+
+func (c *PushCounter) SetCategoryLimit(categoryId string, limit int) {
+	c.c.setCategoryLimit(categoryId, limit)
+}
+
+func (c *PushCounter) Reserve(categoryId string) error {
+	return c.c.reserve(categoryId)
+}
+
+func (c *PushCounter) Release(categoryId string) {
+	c.c.release(categoryId)
+}
+
+func (c *PushCounter) Remaining() int {
+	return c.c.remaining()
+}
+
+func NewFinishedSearchReply() *SearchReply {
+	return &SearchReply{finished: true}
+}
+
+// NewTestingSearchReply creates a reply whose results are passed to
+// TestingPush and TestingPushBatch rather than to the scopes runtime.
+func NewTestingSearchReply(cardinality int) *SearchReply {
+	reply := &SearchReply{logger: newQueryLogger()}
+	reply.counter.cardinality = cardinality
+	return reply
+}
+
+// TestingRegisterCategory records the template of a category for
+// validation, as RegisterCategory does.
+func (reply *SearchReply) TestingRegisterCategory(id, template string) {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	reply.categories.register(id, template)
+}
+
+func (reply *SearchReply) TestingPush(result *CategorisedResult, sent *[]*CategorisedResult) error {
+	_, err := reply.push(result, func(result *CategorisedResult) (bool, error) {
+		*sent = append(*sent, result)
+		return true, nil
+	})
+	return err
+}
+
+func (reply *SearchReply) TestingPushBatch(results []*CategorisedResult, sent *[]*CategorisedResult) error {
+	return reply.pushBatch(results, func(results []*CategorisedResult) (int, error) {
+		*sent = append(*sent, results...)
+		return len(results), nil
+	})
+}
+
+func NewFinishedPreviewReply() *PreviewReply {
+	return &PreviewReply{finished: true}
+}
+
+func NewTestingPreviewReply(cancelled <-chan bool) *PreviewReply {
+	return &PreviewReply{cancelled: cancelled}
+}
+
+// TestingFinish marks the reply finished, taking its lock as Finished
+// and Error do.
+func (reply *PreviewReply) TestingFinish() {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	reply.finished = true
+}
+
+// blockingForward is a forwarded query whose child only finishes once
+// cancelled.
+type blockingForward struct {
+	once      sync.Once
+	cancelled chan struct{}
+}
+
+func (forward *blockingForward) wait() error {
+	<-forward.cancelled
+	return ErrQueryCancelled
+}
+
+func (forward *blockingForward) cancel() {
+	forward.once.Do(func() { close(forward.cancelled) })
+}
+
+func (forward *blockingForward) destroy() {}
+
+// ForwardBlockingPreview forwards a preview to a child that never
+// finishes unless cancelled.
+func ForwardBlockingPreview(ctx context.Context, reply *PreviewReply) error {
+	return forwardPreview(ctx, reply, func() (forwardQuery, error) {
+		return &blockingForward{cancelled: make(chan struct{})}, nil
+	})
+}
+
+func PushFrom(ctx context.Context, cancelled <-chan bool, results <-chan *CategorisedResult, push func(*CategorisedResult) (bool, error)) (int, error) {
+	return pushFrom(ctx, cancelled, results, push)
+}
+
+type ResultDeduper struct {
+	d *resultDeduper
+}
+
+func NewResultDeduper(mode DedupMode) ResultDeduper {
+	return ResultDeduper{newResultDeduper(mode, DedupByURI)}
+}
+
+func (d ResultDeduper) Add(categoryId, key string) (string, bool) {
+	return d.d.add(categoryId, key)
+}
+
+func (d ResultDeduper) Remove(key string) {
+	d.d.remove(key)
+}
+
+func MarshalAttributes(v interface{}) (map[string]interface{}, error) {
+	return marshalAttributes(v)
+}
+
+func DecodeAttributes(data string, v interface{}) error {
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &attrs); err != nil {
+		return err
+	}
+	return decodeAttributes(attrs, v)
+}
+
+func ValidateResult(template, categoryId, attrs string) error {
+	var c categoryComponents
+	c.register(categoryId, template)
+	components, ok := c.lookup(categoryId, ScopeLogger())
+	if !ok {
+		return nil
+	}
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(attrs), &data); err != nil {
+		panic(err)
+	}
+	return validateResult(categoryId, components, data)
+}
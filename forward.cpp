@@ -0,0 +1,184 @@
+#include <cstring>
+#include <future>
+#include <mutex>
+#include <stdexcept>
+
+#include <unity/scopes/ActionMetadata.h>
+#include <unity/scopes/ActivationListenerBase.h>
+#include <unity/scopes/ActivationResponse.h>
+#include <unity/scopes/ColumnLayout.h>
+#include <unity/scopes/CompletionDetails.h>
+#include <unity/scopes/PreviewListenerBase.h>
+#include <unity/scopes/PreviewReply.h>
+#include <unity/scopes/QueryCtrl.h>
+#include <unity/scopes/Result.h>
+#include <unity/scopes/Scope.h>
+#include <unity/scopes/Variant.h>
+
+extern "C" {
+#include "_cgo_export.h"
+}
+#include "helpers.h"
+#include "smartptr_helper.h"
+
+using namespace unity::scopes;
+using namespace gounityscopes::internal;
+
+namespace {
+
+struct Completion {
+    int status;
+    std::string message;
+};
+
+Completion make_completion(CompletionDetails const &details) {
+    switch (details.status()) {
+    case CompletionDetails::OK:
+        return Completion{0, ""};
+    case CompletionDetails::Cancelled:
+        return Completion{1, details.message()};
+    default:
+        return Completion{2, details.message()};
+    }
+}
+
+// PreviewForwarder passes the preview of the child scope on to the
+// reply of the aggregator.  The first error raised while doing so is
+// reported when the child finishes.
+class PreviewForwarder : public PreviewListenerBase {
+public:
+    PreviewForwarder(PreviewReplyProxy const &reply) : reply(reply) {}
+
+    virtual void push(ColumnLayoutList const &layouts) override {
+        try {
+            reply->register_layout(layouts);
+        } catch (...) {
+            // The aggregator may have registered its own layout.
+        }
+    }
+
+    virtual void push(PreviewWidgetList const &widgets) override {
+        try {
+            reply->push(widgets);
+        } catch (const std::exception &e) {
+            record_error(e.what());
+        }
+    }
+
+    virtual void push(std::string const &key, Variant const &value) override {
+        try {
+            reply->push(key, value);
+        } catch (const std::exception &e) {
+            record_error(e.what());
+        }
+    }
+
+    virtual void finished(CompletionDetails const &details) override {
+        Completion c = make_completion(details);
+        {
+            std::lock_guard<std::mutex> lock(error_mutex);
+            if (c.status == 0 && !error.empty()) {
+                c = Completion{2, error};
+            }
+        }
+        completion.set_value(c);
+    }
+
+    std::promise<Completion> completion;
+
+private:
+    void record_error(std::string const &message) {
+        std::lock_guard<std::mutex> lock(error_mutex);
+        if (error.empty()) {
+            error = message;
+        }
+    }
+
+    PreviewReplyProxy reply;
+    std::mutex error_mutex;
+    std::string error;
+};
+
+// ActivationForwarder records the response of the child scope.
+class ActivationForwarder : public ActivationListenerBase {
+public:
+    virtual void activated(ActivationResponse const &r) override {
+        response.reset(new ActivationResponse(r));
+    }
+
+    virtual void finished(CompletionDetails const &details) override {
+        completion.set_value(make_completion(details));
+    }
+
+    std::promise<Completion> completion;
+    std::unique_ptr<ActivationResponse> response;
+};
+
+struct Forward {
+    QueryCtrlProxy ctrl;
+    std::future<Completion> completion;
+    std::shared_ptr<ActivationForwarder> activation;
+};
+
+}
+
+_Forward *result_forward_preview(_Result *res, _ActionMetadata *metadata, SharedPtrData reply, char **error) {
+    try {
+        Result inner = reinterpret_cast<Result*>(res)->retrieve();
+        std::shared_ptr<PreviewForwarder> listener(
+            new PreviewForwarder(get_ptr<PreviewReply>(reply)));
+        std::unique_ptr<Forward> forward(new Forward);
+        forward->completion = listener->completion.get_future();
+        forward->ctrl = inner.target_scope_proxy()->preview(
+            inner, *reinterpret_cast<ActionMetadata*>(metadata), listener);
+        return reinterpret_cast<_Forward*>(forward.release());
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+}
+
+_Forward *result_forward_activation(_Result *res, _ActionMetadata *metadata, char **error) {
+    try {
+        Result inner = reinterpret_cast<Result*>(res)->retrieve();
+        std::unique_ptr<Forward> forward(new Forward);
+        forward->activation.reset(new ActivationForwarder);
+        forward->completion = forward->activation->completion.get_future();
+        forward->ctrl = inner.target_scope_proxy()->activate(
+            inner, *reinterpret_cast<ActionMetadata*>(metadata),
+            forward->activation);
+        return reinterpret_cast<_Forward*>(forward.release());
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+}
+
+int forward_wait(_Forward *forward, char **message) {
+    Completion c = reinterpret_cast<Forward*>(forward)->completion.get();
+    if (c.status != 0) {
+        *message = strdup(c.message.c_str());
+    }
+    return c.status;
+}
+
+void forward_cancel(_Forward *forward) {
+    try {
+        reinterpret_cast<Forward*>(forward)->ctrl->cancel();
+    } catch (...) {
+        // The child scope may already have gone away.
+    }
+}
+
+_ActivationResponse *forward_get_activation_response(_Forward *forward) {
+    Forward *f = reinterpret_cast<Forward*>(forward);
+    if (!f->activation || !f->activation->response) {
+        return nullptr;
+    }
+    return reinterpret_cast<_ActivationResponse*>(
+        new ActivationResponse(*f->activation->response));
+}
+
+void destroy_forward(_Forward *forward) {
+    delete reinterpret_cast<Forward*>(forward);
+}
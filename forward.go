@@ -0,0 +1,145 @@
+package scopes
+
+// #include <stdlib.h>
+// #include "shim.h"
+import "C"
+import (
+	"context"
+	"errors"
+	"runtime"
+)
+
+// ErrNoStoredResult is returned when forwarding a query for a result
+// that does not hold a result stored with StoreResult.
+var ErrNoStoredResult = errors.New("result has no stored result")
+
+// ForwardPreview forwards the preview request for a result wrapping
+// the result of a child scope to that child.  The layouts, widgets and
+// attributes sent by the child are passed on to reply.  It returns
+// once the child has finished, or ErrQueryCancelled if ctx is done or
+// the preview query is cancelled or times out first.  The reply stays
+// locked meanwhile, so other calls to its methods wait for the child
+// to finish.
+//
+// Scopes implementing ContextScope can pass the context they receive.
+// Since the forwarded preview is cancelled along with the query,
+// others can pass context.Background().
+//
+// It is only needed for results stored with interceptActivation set:
+// the client sends the other requests to the child directly.
+func (res *Result) ForwardPreview(ctx context.Context, metadata *ActionMetadata, reply *PreviewReply) error {
+	if !res.HasStoredResult() {
+		return ErrNoStoredResult
+	}
+	return forwardPreview(ctx, reply, func() (forwardQuery, error) {
+		var errorString *C.char
+		forward := C.result_forward_preview(res.result, (*C._ActionMetadata)(metadata.m), &reply.r[0], &errorString)
+		if err := checkError(errorString); err != nil {
+			return nil, err
+		}
+		return childForward{forward}, nil
+	})
+}
+
+// ForwardActivation forwards the activation request for a result
+// wrapping the result of a child scope to that child, and returns the
+// child's response.  If ctx is done before the child responds, the
+// request is cancelled and ErrQueryCancelled is returned.
+//
+// Scopes implementing ContextActivator can pass the context they
+// receive, which is cancelled when the activation times out.  Others
+// can bound the wait with context.WithTimeout.
+func (res *Result) ForwardActivation(ctx context.Context, metadata *ActionMetadata) (*ActivationResponse, error) {
+	if !res.HasStoredResult() {
+		return nil, ErrNoStoredResult
+	}
+	var errorString *C.char
+	forward := C.result_forward_activation(res.result, (*C._ActionMetadata)(metadata.m), &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	defer C.destroy_forward(forward)
+	if err := waitForward(ctx, nil, childForward{forward}); err != nil {
+		return nil, err
+	}
+	response := &ActivationResponse{Status: ActivationNotHandled}
+	if r := C.forward_get_activation_response(forward); r != nil {
+		runtime.SetFinalizer(response, finalizeActivationResponse)
+		response.forwarded = r
+		response.Status = ActivationStatus(C.activation_response_get_status(r))
+	}
+	return response, nil
+}
+
+// forwardQuery is a query forwarded to a child scope.
+type forwardQuery interface {
+	// wait blocks until the child has finished, and returns
+	// ErrQueryCancelled if the query was cancelled.
+	wait() error
+	cancel()
+	destroy()
+}
+
+// childForward is a query forwarded through the scopes runtime.
+type childForward struct {
+	f *C._Forward
+}
+
+func (forward childForward) wait() error {
+	var message *C.char
+	status := C.forward_wait(forward.f, &message)
+	err := checkError(message)
+	switch status {
+	case 0:
+		return nil
+	case 1:
+		return ErrQueryCancelled
+	default:
+		return err
+	}
+}
+
+func (forward childForward) cancel() {
+	C.forward_cancel(forward.f)
+}
+
+func (forward childForward) destroy() {
+	C.destroy_forward(forward.f)
+}
+
+// forwardPreview starts a forwarded preview with start, and waits for
+// it to finish while holding the reply's lock.
+func forwardPreview(ctx context.Context, reply *PreviewReply, start func() (forwardQuery, error)) error {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
+	forward, err := start()
+	if err != nil {
+		return err
+	}
+	defer forward.destroy()
+	return waitForward(ctx, reply.cancelled, forward)
+}
+
+// waitForward waits for a forwarded query to finish, cancelling it if
+// ctx is done or the cancelled channel is closed first.
+func waitForward(ctx context.Context, cancelled <-chan bool, forward forwardQuery) error {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			forward.cancel()
+		case <-cancelled:
+			forward.cancel()
+		case <-done:
+		}
+	}()
+	err := forward.wait()
+	close(done)
+	<-stopped
+	return err
+}
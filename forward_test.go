@@ -0,0 +1,55 @@
+package scopes_test
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+// finishingReply completes a preview reply the way PreviewReply.Error
+// does, without the scopes runtime.
+type finishingReply struct {
+	reply *scopes.PreviewReply
+	done  chan error
+}
+
+func (r *finishingReply) Finished() {
+	r.Error(nil)
+}
+
+func (r *finishingReply) Error(err error) {
+	r.reply.TestingFinish()
+	r.done <- err
+}
+
+func (s *S) TestForwardPreviewTimeout(c *C) {
+	cancel := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(cancel)
+	reply := scopes.NewTestingPreviewReply(cancel)
+	timedOut := &finishingReply{reply, make(chan error, 1)}
+	timer := scopes.NewQueryTimer(10*time.Millisecond, cancel, timedOut)
+	defer scopes.StopQueryTimer(timer)
+
+	// The context is never done, but the timeout cancels the
+	// forwarded preview, so that the timer can complete the reply.
+	err := scopes.ForwardBlockingPreview(context.Background(), reply)
+	c.Check(err, Equals, scopes.ErrQueryCancelled)
+	select {
+	case err := <-timedOut.done:
+		c.Check(err, Equals, scopes.ErrQueryTimeout)
+	case <-time.After(time.Second):
+		c.Fatal("reply not completed after timeout")
+	}
+	c.Check(scopes.ForwardBlockingPreview(context.Background(), reply), Equals, scopes.ErrReplyFinished)
+}
+
+func (s *S) TestForwardPreviewContextDone(c *C) {
+	reply := scopes.NewTestingPreviewReply(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := scopes.ForwardBlockingPreview(ctx, reply)
+	c.Check(err, Equals, scopes.ErrQueryCancelled)
+	c.Check(ctx.Err(), Equals, context.DeadlineExceeded)
+}
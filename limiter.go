@@ -0,0 +1,174 @@
+package scopes
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrTooManyQueries is reported to the client when a query
+	// arrives while the queue of pending queries is full.
+	ErrTooManyQueries = errors.New("too many scope queries pending")
+
+	// ErrQuerySuperseded is reported to the client when a pending
+	// query is cancelled to make room for a newer one.
+	ErrQuerySuperseded = errors.New("scope query superseded by a newer query")
+
+	// ErrQueryCancelled is returned when the client cancelled the
+	// query, whether it was waiting to run or pushing results.
+	ErrQueryCancelled = errors.New("scope query cancelled")
+)
+
+// QueryLimitPolicy selects what happens to a query that arrives when
+// the maximum number of queries are already running and the queue of
+// pending queries is full.
+type QueryLimitPolicy int
+
+const (
+	// RejectNewest rejects the incoming query with
+	// ErrTooManyQueries.
+	RejectNewest QueryLimitPolicy = iota
+
+	// CancelOldestPending cancels the query that has been waiting
+	// the longest with ErrQuerySuperseded, and queues the incoming
+	// query in its place.  This suits search-as-you-type, where
+	// only the latest query is of interest.
+	CancelOldestPending
+)
+
+// QueryLimits bounds the number of Search and Preview calls running
+// concurrently.  Queries over the limit wait in a queue until a
+// running query returns.
+type QueryLimits struct {
+	// MaxSearches is the maximum number of Search calls running at
+	// once.  If zero, Search calls are not limited.
+	MaxSearches int
+
+	// MaxPreviews is the maximum number of Preview calls running
+	// at once.  If zero, Preview calls are not limited.
+	MaxPreviews int
+
+	// MaxPending is the maximum number of queries of each kind
+	// waiting to run.  If zero, the queue is unbounded with the
+	// RejectNewest policy, and holds a single query with the
+	// CancelOldestPending policy.
+	MaxPending int
+
+	// Policy selects what happens to queries arriving when the
+	// queue is full.
+	Policy QueryLimitPolicy
+}
+
+// SetQueryLimits sets the limits on concurrently running queries.
+// Queries already running are not affected, but queries waiting in
+// the queue are started if the new limits allow it.
+func SetQueryLimits(limits QueryLimits) {
+	searchLimiter.configure(limits.MaxSearches, limits.MaxPending, limits.Policy)
+	previewLimiter.configure(limits.MaxPreviews, limits.MaxPending, limits.Policy)
+}
+
+var (
+	searchLimiter  queryLimiter
+	previewLimiter queryLimiter
+)
+
+// pendingQuery is a query waiting in a queryLimiter's queue.  The
+// result of the wait is sent on ready: nil if the query may run, or
+// the error to report otherwise.
+type pendingQuery struct {
+	cancel chan bool
+	ready  chan error
+}
+
+// queryLimiter is a semaphore bounding the number of queries of one
+// kind running at once.
+type queryLimiter struct {
+	lock       sync.Mutex
+	max        int
+	maxPending int
+	policy     QueryLimitPolicy
+	running    int
+	pending    list.List
+}
+
+func (l *queryLimiter) configure(max, maxPending int, policy QueryLimitPolicy) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.max = max
+	l.maxPending = maxPending
+	l.policy = policy
+	for l.pending.Len() > 0 && (l.max <= 0 || l.running < l.max) {
+		l.running++
+		l.pop().ready <- nil
+	}
+}
+
+func (l *queryLimiter) pop() *pendingQuery {
+	return l.pending.Remove(l.pending.Front()).(*pendingQuery)
+}
+
+// acquire waits until the query may run.  It returns an error if the
+// query was rejected, superseded or cancelled while waiting.  If it
+// returns nil, release must be called once the query completes.
+func (l *queryLimiter) acquire(cancel chan bool) error {
+	l.lock.Lock()
+	if l.max <= 0 || l.running < l.max {
+		l.running++
+		l.lock.Unlock()
+		return nil
+	}
+	maxPending := l.maxPending
+	if maxPending <= 0 && l.policy == CancelOldestPending {
+		maxPending = 1
+	}
+	if maxPending > 0 && l.pending.Len() >= maxPending {
+		if l.policy != CancelOldestPending {
+			l.lock.Unlock()
+			return ErrTooManyQueries
+		}
+		oldest := l.pop()
+		oldest.ready <- ErrQuerySuperseded
+		sendCancelChannel(oldest.cancel)
+	}
+	query := &pendingQuery{cancel: cancel, ready: make(chan error, 1)}
+	elem := l.pending.PushBack(query)
+	l.lock.Unlock()
+
+	select {
+	case err := <-query.ready:
+		return err
+	case <-cancel:
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	select {
+	case err := <-query.ready:
+		// The query was dequeued before we noticed the
+		// cancellation.
+		if err == nil {
+			l.releaseLocked()
+			return ErrQueryCancelled
+		}
+		return err
+	default:
+		l.pending.Remove(elem)
+		return ErrQueryCancelled
+	}
+}
+
+// release frees the slot held by a running query, handing it to the
+// oldest pending query if there is one.
+func (l *queryLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.releaseLocked()
+}
+
+func (l *queryLimiter) releaseLocked() {
+	if l.pending.Len() > 0 && (l.max <= 0 || l.running <= l.max) {
+		l.pop().ready <- nil
+		return
+	}
+	l.running--
+}
@@ -0,0 +1,95 @@
+package scopes_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func acquireAsync(acquire func(chan bool) error, ch chan bool) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		result <- acquire(ch)
+	}()
+	return result
+}
+
+func checkWaiting(c *C, result <-chan error) {
+	select {
+	case err := <-result:
+		c.Fatalf("query was not queued: %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func (s *S) TestQueryLimiterQueue(c *C) {
+	acquire, release := scopes.NewQueryLimiter(1, 1, scopes.RejectNewest)
+	ch1, ch2, ch3 := scopes.MakeCancelChannel(), scopes.MakeCancelChannel(), scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch1)
+	defer scopes.ReleaseCancelChannel(ch2)
+	defer scopes.ReleaseCancelChannel(ch3)
+
+	c.Assert(acquire(ch1), IsNil)
+	second := acquireAsync(acquire, ch2)
+	checkWaiting(c, second)
+
+	// The queue is full.
+	c.Check(acquire(ch3), Equals, scopes.ErrTooManyQueries)
+
+	release()
+	c.Check(<-second, IsNil)
+	release()
+}
+
+func (s *S) TestQueryLimiterCancelOldestPending(c *C) {
+	acquire, release := scopes.NewQueryLimiter(1, 0, scopes.CancelOldestPending)
+	ch1, ch2, ch3 := scopes.MakeCancelChannel(), scopes.MakeCancelChannel(), scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch1)
+	defer scopes.ReleaseCancelChannel(ch2)
+	defer scopes.ReleaseCancelChannel(ch3)
+
+	c.Assert(acquire(ch1), IsNil)
+	second := acquireAsync(acquire, ch2)
+	checkWaiting(c, second)
+	third := acquireAsync(acquire, ch3)
+
+	c.Check(<-second, Equals, scopes.ErrQuerySuperseded)
+	c.Check(scopes.IsCancelled(ch2), Equals, true)
+	checkWaiting(c, third)
+
+	release()
+	c.Check(<-third, IsNil)
+	release()
+}
+
+func (s *S) TestQueryLimiterCancelWhileWaiting(c *C) {
+	acquire, release := scopes.NewQueryLimiter(1, 0, scopes.RejectNewest)
+	ch1, ch2, ch3 := scopes.MakeCancelChannel(), scopes.MakeCancelChannel(), scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch1)
+	defer scopes.ReleaseCancelChannel(ch2)
+	defer scopes.ReleaseCancelChannel(ch3)
+
+	c.Assert(acquire(ch1), IsNil)
+	second := acquireAsync(acquire, ch2)
+	checkWaiting(c, second)
+	scopes.SendCancelChannel(ch2)
+	c.Check(<-second, Equals, scopes.ErrQueryCancelled)
+
+	// The cancelled query does not hold on to a slot.
+	release()
+	c.Check(acquire(ch3), IsNil)
+	release()
+}
+
+func (s *S) TestQueryLimiterUnlimited(c *C) {
+	acquire, release := scopes.NewQueryLimiter(0, 0, scopes.RejectNewest)
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	for i := 0; i < 10; i++ {
+		c.Check(acquire(ch), IsNil)
+	}
+	for i := 0; i < 10; i++ {
+		release()
+	}
+}
@@ -0,0 +1,275 @@
+package scopes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is the severity of a log entry.
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarning
+	LogError
+)
+
+func (level LogLevel) String() string {
+	switch level {
+	case LogDebug:
+		return "DEBUG"
+	case LogInfo:
+		return "INFO"
+	case LogWarning:
+		return "WARNING"
+	case LogError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LogLevel(%d)", int(level))
+	}
+}
+
+// LogField is a key/value pair attached to a log entry.
+type LogField struct {
+	Key   string
+	Value string
+}
+
+// LogEntry is a message passed to the log sinks.
+type LogEntry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	Fields  []LogField
+}
+
+// LogSink is the interface implemented by log destinations.  Sinks
+// may be called from several goroutines at once.
+type LogSink interface {
+	WriteLog(entry *LogEntry) error
+}
+
+// Names of the fields added to the entries of query loggers.
+const (
+	LogFieldScopeId      = "scope_id"
+	LogFieldQueryId      = "query_id"
+	LogFieldQuery        = "query"
+	LogFieldDepartmentId = "department_id"
+	LogFieldFormFactor   = "form_factor"
+	LogFieldResultURI    = "result_uri"
+)
+
+var (
+	logSinks    = []LogSink{NewTextLogSink(os.Stderr)}
+	logLevel    = LogInfo
+	logScopeId  string
+	logSinkLock sync.Mutex
+
+	lastQueryId uint64
+)
+
+// SetLogSinks replaces the destinations of the entries written
+// through Logger.  By default, entries are written to standard error.
+// Passing no sinks discards every entry.
+func SetLogSinks(sinks ...LogSink) {
+	logSinkLock.Lock()
+	defer logSinkLock.Unlock()
+	logSinks = append([]LogSink(nil), sinks...)
+}
+
+// SetLogLevel sets the minimum level of the entries passed to the
+// log sinks.  The default is LogInfo.
+func SetLogLevel(level LogLevel) {
+	logSinkLock.Lock()
+	defer logSinkLock.Unlock()
+	logLevel = level
+}
+
+func setLogScopeId(scopeId string) {
+	logSinkLock.Lock()
+	defer logSinkLock.Unlock()
+	logScopeId = scopeId
+}
+
+// Logger writes log entries tagged with a set of fields.  A Logger
+// is safe for concurrent use.
+type Logger struct {
+	fields []LogField
+}
+
+// ScopeLogger returns a logger whose entries are tagged with the ID
+// of the running scope.
+func ScopeLogger() *Logger {
+	logSinkLock.Lock()
+	defer logSinkLock.Unlock()
+	return &Logger{[]LogField{{LogFieldScopeId, logScopeId}}}
+}
+
+// newQueryLogger returns a logger for a new query, tagged with the
+// scope ID, a unique query ID and the given fields.
+func newQueryLogger(fields ...LogField) *Logger {
+	queryId := atomic.AddUint64(&lastQueryId, 1)
+	l := ScopeLogger().With(LogFieldQueryId, strconv.FormatUint(queryId, 10))
+	l.fields = append(l.fields, fields...)
+	return l
+}
+
+// With returns a logger that adds the given field to its entries.
+func (l *Logger) With(key, value string) *Logger {
+	fields := make([]LogField, len(l.fields), len(l.fields)+1)
+	copy(fields, l.fields)
+	return &Logger{append(fields, LogField{key, value})}
+}
+
+// Fields returns the fields added to the entries of the logger.
+func (l *Logger) Fields() []LogField {
+	return append([]LogField(nil), l.fields...)
+}
+
+// Log writes an entry with the given level, formatting the message
+// in the manner of fmt.Sprintf.
+func (l *Logger) Log(level LogLevel, format string, v ...interface{}) {
+	logSinkLock.Lock()
+	sinks := logSinks
+	minLevel := logLevel
+	logSinkLock.Unlock()
+	if level < minLevel || len(sinks) == 0 {
+		return
+	}
+	entry := &LogEntry{
+		Time:    time.Now(),
+		Level:   level,
+		Message: fmt.Sprintf(format, v...),
+		Fields:  l.fields,
+	}
+	for _, sink := range sinks {
+		if err := sink.WriteLog(entry); err != nil {
+			// Reporting the failure through the sinks could
+			// fail again, so it goes straight to stderr.
+			fmt.Fprintf(os.Stderr, "Could not write log entry: %v\n", err)
+		}
+	}
+}
+
+// logf writes a diagnostic message of the library through the scope
+// logger.
+func logf(format string, v ...interface{}) {
+	ScopeLogger().Warningf(format, v...)
+}
+
+// Debugf writes an entry at the LogDebug level.
+func (l *Logger) Debugf(format string, v ...interface{}) {
+	l.Log(LogDebug, format, v...)
+}
+
+// Infof writes an entry at the LogInfo level.
+func (l *Logger) Infof(format string, v ...interface{}) {
+	l.Log(LogInfo, format, v...)
+}
+
+// Warningf writes an entry at the LogWarning level.
+func (l *Logger) Warningf(format string, v ...interface{}) {
+	l.Log(LogWarning, format, v...)
+}
+
+// Errorf writes an entry at the LogError level.
+func (l *Logger) Errorf(format string, v ...interface{}) {
+	l.Log(LogError, format, v...)
+}
+
+// writerSink serialises the entries written to an io.Writer.
+type writerSink struct {
+	lock   sync.Mutex
+	w      io.Writer
+	format func(entry *LogEntry) string
+}
+
+func (s *writerSink) WriteLog(entry *LogEntry) error {
+	line := s.format(entry)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err := io.WriteString(s.w, line)
+	return err
+}
+
+// NewTextLogSink returns a sink writing entries to w as human
+// readable lines, with the fields following the message:
+//
+//	2016-01-02T15:04:05Z INFO searching scope_id=myscope query_id=1
+func NewTextLogSink(w io.Writer) LogSink {
+	return &writerSink{w: w, format: formatTextEntry}
+}
+
+func formatTextEntry(entry *LogEntry) string {
+	var buf strings.Builder
+	buf.WriteString(entry.Time.UTC().Format(time.RFC3339))
+	buf.WriteString(" ")
+	buf.WriteString(entry.Level.String())
+	buf.WriteString(" ")
+	buf.WriteString(entry.Message)
+	for _, field := range entry.Fields {
+		writeLogField(&buf, field.Key, field.Value)
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+// NewKeyValueLogSink returns a sink writing every entry to w as a
+// line of key=value pairs, in the style of journald and logfmt:
+//
+//	time=2016-01-02T15:04:05Z level=INFO msg="searching for foo" scope_id=myscope
+func NewKeyValueLogSink(w io.Writer) LogSink {
+	return &writerSink{w: w, format: formatKeyValueEntry}
+}
+
+func formatKeyValueEntry(entry *LogEntry) string {
+	var buf strings.Builder
+	buf.WriteString("time=")
+	buf.WriteString(entry.Time.UTC().Format(time.RFC3339))
+	writeLogField(&buf, "level", entry.Level.String())
+	writeLogField(&buf, "msg", entry.Message)
+	for _, field := range entry.Fields {
+		writeLogField(&buf, field.Key, field.Value)
+	}
+	buf.WriteString("\n")
+	return buf.String()
+}
+
+func writeLogField(buf *strings.Builder, key, value string) {
+	buf.WriteString(" ")
+	buf.WriteString(key)
+	buf.WriteString("=")
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	buf.WriteString(value)
+}
+
+// FileLogSink is a sink appending human readable entries to a file.
+type FileLogSink struct {
+	LogSink
+	f *os.File
+}
+
+// NewFileLogSink opens a sink appending to the named file in the
+// scope's cache directory, creating it if needed.
+func NewFileLogSink(base *ScopeBase, name string) (*FileLogSink, error) {
+	f, err := os.OpenFile(filepath.Join(base.CacheDirectory(), name), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileLogSink{NewTextLogSink(f), f}, nil
+}
+
+// Close closes the file.
+func (s *FileLogSink) Close() error {
+	return s.f.Close()
+}
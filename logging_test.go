@@ -0,0 +1,96 @@
+package scopes_test
+
+import (
+	"bytes"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+type recordingSink struct {
+	entries []*scopes.LogEntry
+}
+
+func (s *recordingSink) WriteLog(entry *scopes.LogEntry) error {
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+func (s *S) TestLoggerLevels(c *C) {
+	sink := &recordingSink{}
+	scopes.SetLogSinks(sink)
+	defer scopes.SetLogSinks(scopes.NewTextLogSink(os.Stderr))
+	scopes.SetLogLevel(scopes.LogWarning)
+	defer scopes.SetLogLevel(scopes.LogInfo)
+
+	logger := scopes.ScopeLogger()
+	logger.Infof("ignored")
+	logger.Warningf("warning %d", 1)
+	logger.Errorf("error %d", 2)
+
+	c.Assert(sink.entries, HasLen, 2)
+	c.Check(sink.entries[0].Level, Equals, scopes.LogWarning)
+	c.Check(sink.entries[0].Message, Equals, "warning 1")
+	c.Check(sink.entries[1].Level, Equals, scopes.LogError)
+	c.Check(sink.entries[1].Message, Equals, "error 2")
+}
+
+func (s *S) TestQueryLogger(c *C) {
+	sink := &recordingSink{}
+	scopes.SetLogSinks(sink)
+	defer scopes.SetLogSinks(scopes.NewTextLogSink(os.Stderr))
+
+	l1 := scopes.NewQueryLogger(scopes.LogField{scopes.LogFieldQuery, "foo"})
+	l2 := scopes.NewQueryLogger()
+	c.Check(l1.Fields()[0].Key, Equals, scopes.LogFieldScopeId)
+	c.Check(l1.Fields()[1].Key, Equals, scopes.LogFieldQueryId)
+	c.Check(l1.Fields()[1].Value, Not(Equals), l2.Fields()[1].Value)
+	c.Check(l1.Fields()[2], Equals, scopes.LogField{scopes.LogFieldQuery, "foo"})
+
+	// With does not modify the parent logger.
+	l3 := l1.With("backend", "http")
+	c.Check(l1.Fields(), HasLen, 3)
+	c.Check(l3.Fields(), HasLen, 4)
+
+	l3.Infof("hello")
+	c.Assert(sink.entries, HasLen, 1)
+	c.Check(sink.entries[0].Fields, DeepEquals, l3.Fields())
+}
+
+func (s *S) TestLibraryDiagnostics(c *C) {
+	sink := &recordingSink{}
+	scopes.SetLogSinks(sink)
+	defer scopes.SetLogSinks(scopes.NewTextLogSink(os.Stderr))
+
+	scopes.Logf("Could not write %s", "metrics")
+	c.Assert(sink.entries, HasLen, 1)
+	c.Check(sink.entries[0].Level, Equals, scopes.LogWarning)
+	c.Check(sink.entries[0].Message, Equals, "Could not write metrics")
+	c.Check(sink.entries[0].Fields[0].Key, Equals, scopes.LogFieldScopeId)
+}
+
+func (s *S) TestTextLogSink(c *C) {
+	var buf bytes.Buffer
+	err := scopes.NewTextLogSink(&buf).WriteLog(&scopes.LogEntry{
+		Time:    time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC),
+		Level:   scopes.LogInfo,
+		Message: "searching",
+		Fields:  []scopes.LogField{{"scope_id", "myscope"}, {"query", "two words"}},
+	})
+	c.Assert(err, IsNil)
+	c.Check(buf.String(), Equals, "2016-01-02T15:04:05Z INFO searching scope_id=myscope query=\"two words\"\n")
+}
+
+func (s *S) TestKeyValueLogSink(c *C) {
+	var buf bytes.Buffer
+	err := scopes.NewKeyValueLogSink(&buf).WriteLog(&scopes.LogEntry{
+		Time:    time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC),
+		Level:   scopes.LogError,
+		Message: "backend failed",
+		Fields:  []scopes.LogField{{"scope_id", "myscope"}, {"department_id", ""}},
+	})
+	c.Assert(err, IsNil)
+	c.Check(buf.String(), Equals, "time=2016-01-02T15:04:05Z level=ERROR msg=\"backend failed\" scope_id=myscope department_id=\"\"\n")
+}
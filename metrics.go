@@ -0,0 +1,236 @@
+package scopes
+
+import (
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the buckets of the query
+// latency histograms.
+var LatencyBuckets = []time.Duration{
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LatencyHistogram records the distribution of query latencies.
+// Counts[i] is the number of queries that took at most
+// LatencyBuckets[i] and more than the previous bound, and the last
+// element counts the queries slower than every bound.
+type LatencyHistogram struct {
+	Counts []uint64
+	Total  time.Duration
+}
+
+func (h *LatencyHistogram) add(latency time.Duration) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(LatencyBuckets)+1)
+	}
+	i := 0
+	for i < len(LatencyBuckets) && latency > LatencyBuckets[i] {
+		i++
+	}
+	h.Counts[i]++
+	h.Total += latency
+}
+
+// QueryMetrics holds the counters for one kind of query.  Every query
+// is counted once in Started, and once in one of the other counters
+// when it completes.
+type QueryMetrics struct {
+	Started   uint64
+	Succeeded uint64
+	Failed    uint64
+	Cancelled uint64
+	TimedOut  uint64
+	// Rejected counts the queries dropped by the query limits.
+	Rejected uint64
+	Latency  LatencyHistogram
+}
+
+// MetricsSnapshot is a copy of the runtime metrics of the scope.
+type MetricsSnapshot struct {
+	ScopeId       string
+	Search        QueryMetrics
+	Preview       QueryMetrics
+	ResultsPushed uint64
+}
+
+type queryOutcome int
+
+const (
+	querySucceeded queryOutcome = iota
+	queryFailed
+	queryCancelled
+	queryTimedOut
+	queryRejected
+)
+
+// outcomeOf classifies the way a query completed.
+func outcomeOf(err error, cancelled <-chan bool, timedOut bool) queryOutcome {
+	switch {
+	case timedOut:
+		return queryTimedOut
+	case err == ErrTooManyQueries || err == ErrQuerySuperseded:
+		return queryRejected
+	case isCancelled(cancelled):
+		return queryCancelled
+	case err != nil && !pushStopped(err):
+		return queryFailed
+	default:
+		return querySucceeded
+	}
+}
+
+var (
+	metrics     MetricsSnapshot
+	metricsLock sync.Mutex
+)
+
+func init() {
+	expvar.Publish("unityscopes", expvar.Func(func() interface{} {
+		return Metrics()
+	}))
+}
+
+// Metrics returns a snapshot of the metrics collected for the
+// queries handled by the scope.  The snapshot is also published with
+// the expvar package under the name "unityscopes".
+func Metrics() MetricsSnapshot {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	snapshot := metrics
+	snapshot.Search.Latency.Counts = append([]uint64(nil), metrics.Search.Latency.Counts...)
+	snapshot.Preview.Latency.Counts = append([]uint64(nil), metrics.Preview.Latency.Counts...)
+	return snapshot
+}
+
+func setMetricsScopeId(scopeId string) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	metrics.ScopeId = scopeId
+}
+
+func queryStarted(m *QueryMetrics) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	m.Started++
+}
+
+func queryFinished(m *QueryMetrics, latency time.Duration, outcome queryOutcome) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	switch outcome {
+	case querySucceeded:
+		m.Succeeded++
+	case queryFailed:
+		m.Failed++
+	case queryCancelled:
+		m.Cancelled++
+	case queryTimedOut:
+		m.TimedOut++
+	case queryRejected:
+		m.Rejected++
+	}
+	m.Latency.add(latency)
+}
+
+func resultsPushed(n int) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	metrics.ResultsPushed += uint64(n)
+}
+
+// metricsFileName is the name of the file in the scope's cache
+// directory the metrics are written to.
+const metricsFileName = "metrics.json"
+
+// writeMetricsFile writes the current metrics to the given directory.
+// The file is replaced atomically, so readers never see a partial
+// snapshot.
+func writeMetricsFile(dir string) error {
+	data, err := json.MarshalIndent(Metrics(), "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, metricsFileName)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, metricsFileName))
+}
+
+var (
+	metricsInterval time.Duration
+	metricsStop     chan struct{}
+	metricsDumpDir  string
+	metricsDumpLock sync.Mutex
+)
+
+func setMetricsInterval(interval time.Duration) {
+	metricsDumpLock.Lock()
+	defer metricsDumpLock.Unlock()
+	metricsInterval = interval
+}
+
+// startMetricsDump starts writing the metrics to the given directory
+// every metricsInterval, if an interval has been configured.
+func startMetricsDump(dir string) {
+	metricsDumpLock.Lock()
+	defer metricsDumpLock.Unlock()
+	if metricsInterval <= 0 || metricsStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	metricsStop = stop
+	metricsDumpDir = dir
+	go func(interval time.Duration) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+			if err := writeMetricsFile(dir); err != nil {
+				logf("Could not write scope metrics: %v", err)
+			}
+		}
+	}(metricsInterval)
+}
+
+// stopMetricsDump stops the periodic metrics dump, writing the final
+// metrics before returning.
+func stopMetricsDump() {
+	metricsDumpLock.Lock()
+	defer metricsDumpLock.Unlock()
+	if metricsStop == nil {
+		return
+	}
+	close(metricsStop)
+	metricsStop = nil
+	if err := writeMetricsFile(metricsDumpDir); err != nil {
+		logf("Could not write scope metrics: %v", err)
+	}
+}
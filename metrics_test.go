@@ -0,0 +1,64 @@
+package scopes_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestMetrics(c *C) {
+	scopes.ResetMetrics()
+	defer scopes.ResetMetrics()
+
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	scopes.RecordSearch(5*time.Millisecond, nil, ch, false)
+	scopes.RecordSearch(200*time.Millisecond, errors.New("failure"), ch, false)
+	scopes.RecordSearch(time.Minute, scopes.ErrQueryTimeout, ch, true)
+	scopes.RecordSearch(0, scopes.ErrTooManyQueries, ch, false)
+	// A scope stopping once the client has enough results succeeds.
+	scopes.RecordSearch(0, scopes.ErrCardinalityReached, ch, false)
+	scopes.SendCancelChannel(ch)
+	scopes.RecordSearch(time.Second, nil, ch, false)
+	scopes.ResultsPushed(3)
+
+	m := scopes.Metrics()
+	c.Check(m.Search.Started, Equals, uint64(6))
+	c.Check(m.Search.Succeeded, Equals, uint64(2))
+	c.Check(m.Search.Failed, Equals, uint64(1))
+	c.Check(m.Search.TimedOut, Equals, uint64(1))
+	c.Check(m.Search.Rejected, Equals, uint64(1))
+	c.Check(m.Search.Cancelled, Equals, uint64(1))
+	c.Check(m.Search.Latency.Counts, DeepEquals, []uint64{3, 0, 0, 1, 0, 1, 0, 0, 0, 1})
+	c.Check(m.Search.Latency.Total, Equals, time.Minute+time.Second+205*time.Millisecond)
+	c.Check(m.Preview.Started, Equals, uint64(0))
+	c.Check(m.ResultsPushed, Equals, uint64(3))
+
+	// Snapshots are not affected by later queries.
+	scopes.RecordSearch(0, nil, nil, false)
+	c.Check(m.Search.Latency.Counts[0], Equals, uint64(3))
+}
+
+func (s *S) TestWriteMetricsFile(c *C) {
+	scopes.ResetMetrics()
+	defer scopes.ResetMetrics()
+	scopes.ResultsPushed(42)
+
+	dir := c.MkDir()
+	c.Assert(scopes.WriteMetricsFile(dir), IsNil)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "metrics.json"))
+	c.Assert(err, IsNil)
+	var m scopes.MetricsSnapshot
+	c.Assert(json.Unmarshal(data, &m), IsNil)
+	c.Check(m.ResultsPushed, Equals, uint64(42))
+
+	// Only the metrics file is left in the directory.
+	files, err := ioutil.ReadDir(dir)
+	c.Assert(err, IsNil)
+	c.Check(files, HasLen, 1)
+}
@@ -0,0 +1,61 @@
+package scopes
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicHandler is a function that is notified when a scope method
+// panics.  It receives the value passed to panic and the stack trace
+// of the goroutine that panicked.
+type PanicHandler func(value interface{}, stack []byte)
+
+var (
+	panicHandler     PanicHandler
+	panicHandlerLock sync.Mutex
+)
+
+// SetPanicHandler installs a handler that is called whenever a scope
+// method panics, for instance to forward the crash to an error
+// reporting service.  Passing nil removes the handler.
+//
+// Panics are always recovered and reported to the client as an error
+// on the query's reply, whether or not a handler is installed.
+func SetPanicHandler(handler PanicHandler) {
+	panicHandlerLock.Lock()
+	defer panicHandlerLock.Unlock()
+	panicHandler = handler
+}
+
+func currentPanicHandler() PanicHandler {
+	panicHandlerLock.Lock()
+	defer panicHandlerLock.Unlock()
+	return panicHandler
+}
+
+// PanicError is the error reported for a query whose scope method
+// panicked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("scope panicked: %v", e.Value)
+}
+
+// callProtected calls f, converting any panic into a *PanicError.
+func callProtected(f func() error) (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			stack := debug.Stack()
+			ScopeLogger().Errorf("Recovered from panic in scope: %v\n%s", value, stack)
+			if handler := currentPanicHandler(); handler != nil {
+				handler(value, stack)
+			}
+			err = &PanicError{value, stack}
+		}
+	}()
+	return f()
+}
@@ -0,0 +1,32 @@
+package scopes_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestCallProtected(c *C) {
+	c.Check(scopes.CallProtected(func() error { return nil }), IsNil)
+
+	err := errors.New("failure")
+	c.Check(scopes.CallProtected(func() error { return err }), Equals, err)
+}
+
+func (s *S) TestCallProtectedPanic(c *C) {
+	var handled interface{}
+	scopes.SetPanicHandler(func(value interface{}, stack []byte) {
+		handled = value
+		c.Check(len(stack), Not(Equals), 0)
+	})
+	defer scopes.SetPanicHandler(nil)
+
+	err := scopes.CallProtected(func() error {
+		scopes.NewOptionSelectorFilter("f1", "Options", false).UpdateState(scopes.FilterState{}, "missing", true)
+		return nil
+	})
+	c.Assert(err, FitsTypeOf, &scopes.PanicError{})
+	c.Check(err.Error(), Equals, "scope panicked: invalid option ID")
+	c.Check(handled, Equals, "invalid option ID")
+}
@@ -0,0 +1,195 @@
+package scopes
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Values accepted for RendererTemplate.CategoryLayout.
+const (
+	CategoryLayoutGrid            = "grid"
+	CategoryLayoutCarousel        = "carousel"
+	CategoryLayoutVerticalJournal = "vertical-journal"
+	CategoryLayoutHorizontalList  = "horizontal-list"
+)
+
+// Values accepted for RendererTemplate.CardSize.
+const (
+	CardSizeSmall  = "small"
+	CardSizeMedium = "medium"
+	CardSizeLarge  = "large"
+)
+
+// Values accepted for RendererTemplate.CardLayout.
+const (
+	CardLayoutVertical   = "vertical"
+	CardLayoutHorizontal = "horizontal"
+)
+
+// Values accepted for RendererTemplate.QuickPreviewType.
+const (
+	QuickPreviewAudio = "audio"
+	QuickPreviewVideo = "video"
+)
+
+// CategoryRenderer describes how the client should display the
+// results of a category.  It is the typed equivalent of the JSON
+// template accepted by RegisterCategory, and can be registered with
+// RegisterCategoryWithRenderer.
+type CategoryRenderer struct {
+	SchemaVersion int                          `json:"schema-version"`
+	Template      RendererTemplate             `json:"template"`
+	Components    map[string]RendererComponent `json:"components"`
+}
+
+// RendererTemplate holds the layout settings of a CategoryRenderer.
+// Empty fields are left to the client's defaults.
+type RendererTemplate struct {
+	CategoryLayout   string `json:"category-layout,omitempty"`
+	CardSize         string `json:"card-size,omitempty"`
+	CardLayout       string `json:"card-layout,omitempty"`
+	Overlay          bool   `json:"overlay,omitempty"`
+	CollapsedRows    int    `json:"collapsed-rows,omitempty"`
+	QuickPreviewType string `json:"quick-preview-type,omitempty"`
+	// CardBackground is a URI such as "color:///#ffffff" or
+	// "gradient:///#ffffff/#000000", or the URI of an image.
+	CardBackground string `json:"card-background,omitempty"`
+}
+
+// RendererComponent maps a card component to the result attribute
+// providing its value.
+type RendererComponent struct {
+	Field string
+	// Attributes holds additional settings of the component, such
+	// as "aspect-ratio" or "fill-mode" for art.
+	Attributes map[string]interface{}
+}
+
+// MarshalJSON encodes the component as a plain field name when it has
+// no additional attributes.
+func (c RendererComponent) MarshalJSON() ([]byte, error) {
+	if len(c.Attributes) == 0 {
+		return json.Marshal(c.Field)
+	}
+	v := make(map[string]interface{}, len(c.Attributes)+1)
+	for key, value := range c.Attributes {
+		v[key] = value
+	}
+	v["field"] = c.Field
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON decodes either form of component mapping.
+func (c *RendererComponent) UnmarshalJSON(data []byte) error {
+	var field string
+	if err := json.Unmarshal(data, &field); err == nil {
+		*c = RendererComponent{Field: field}
+		return nil
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	field, _ = v["field"].(string)
+	delete(v, "field")
+	if len(v) == 0 {
+		v = nil
+	}
+	*c = RendererComponent{Field: field, Attributes: v}
+	return nil
+}
+
+// Names of the components a card can display.
+var rendererComponentNames = map[string]bool{
+	"title":         true,
+	"art":           true,
+	"subtitle":      true,
+	"mascot":        true,
+	"emblem":        true,
+	"summary":       true,
+	"attributes":    true,
+	"overlay-color": true,
+	"background":    true,
+}
+
+// NewCategoryRenderer returns a renderer displaying small cards in a
+// grid, with no components mapped.
+func NewCategoryRenderer() *CategoryRenderer {
+	return &CategoryRenderer{
+		SchemaVersion: 1,
+		Template: RendererTemplate{
+			CategoryLayout: CategoryLayoutGrid,
+			CardSize:       CardSizeSmall,
+		},
+		Components: make(map[string]RendererComponent),
+	}
+}
+
+// AddComponent maps the named card component to a result attribute.
+func (r *CategoryRenderer) AddComponent(name, field string) {
+	if r.Components == nil {
+		r.Components = make(map[string]RendererComponent)
+	}
+	r.Components[name] = RendererComponent{Field: field}
+}
+
+func checkRendererValue(name, value string, allowed ...string) error {
+	if value == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return fmt.Errorf("Invalid %s %q", name, value)
+}
+
+// Validate checks that the renderer only uses settings and components
+// known to the client, which would otherwise silently ignore them.
+func (r *CategoryRenderer) Validate() error {
+	if r.SchemaVersion != 1 {
+		return fmt.Errorf("Unsupported schema-version %d", r.SchemaVersion)
+	}
+	t := &r.Template
+	if err := checkRendererValue("category-layout", t.CategoryLayout, CategoryLayoutGrid, CategoryLayoutCarousel, CategoryLayoutVerticalJournal, CategoryLayoutHorizontalList); err != nil {
+		return err
+	}
+	if err := checkRendererValue("card-size", t.CardSize, CardSizeSmall, CardSizeMedium, CardSizeLarge); err != nil {
+		return err
+	}
+	if err := checkRendererValue("card-layout", t.CardLayout, CardLayoutVertical, CardLayoutHorizontal); err != nil {
+		return err
+	}
+	if err := checkRendererValue("quick-preview-type", t.QuickPreviewType, QuickPreviewAudio, QuickPreviewVideo); err != nil {
+		return err
+	}
+	if t.CollapsedRows < 0 {
+		return fmt.Errorf("Invalid collapsed-rows %d", t.CollapsedRows)
+	}
+	names := make([]string, 0, len(r.Components))
+	for name := range r.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !rendererComponentNames[name] {
+			return fmt.Errorf("Unknown component %q", name)
+		}
+		if r.Components[name].Field == "" {
+			return fmt.Errorf("Component %q is not mapped to a field", name)
+		}
+	}
+	return nil
+}
+
+// MarshalTemplate returns the JSON template for the renderer, as
+// accepted by RegisterCategory.
+func (r *CategoryRenderer) MarshalTemplate() (string, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
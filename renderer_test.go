@@ -0,0 +1,71 @@
+package scopes_test
+
+import (
+	"encoding/json"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestCategoryRendererMarshal(c *C) {
+	r := scopes.NewCategoryRenderer()
+	r.Template.CardLayout = scopes.CardLayoutHorizontal
+	r.Template.CollapsedRows = 1
+	r.AddComponent("title", "title")
+	r.Components["art"] = scopes.RendererComponent{
+		Field:      "art",
+		Attributes: map[string]interface{}{"aspect-ratio": 1.6},
+	}
+	c.Assert(r.Validate(), IsNil)
+
+	template, err := r.MarshalTemplate()
+	c.Assert(err, IsNil)
+	var v map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(template), &v), IsNil)
+	c.Check(v, DeepEquals, map[string]interface{}{
+		"schema-version": 1.0,
+		"template": map[string]interface{}{
+			"category-layout": "grid",
+			"card-size":       "small",
+			"card-layout":     "horizontal",
+			"collapsed-rows":  1.0,
+		},
+		"components": map[string]interface{}{
+			"title": "title",
+			"art": map[string]interface{}{
+				"field":        "art",
+				"aspect-ratio": 1.6,
+			},
+		},
+	})
+
+	var decoded scopes.CategoryRenderer
+	c.Assert(json.Unmarshal([]byte(template), &decoded), IsNil)
+	c.Check(&decoded, DeepEquals, r)
+}
+
+func (s *S) TestCategoryRendererValidate(c *C) {
+	r := scopes.NewCategoryRenderer()
+	r.Template.CategoryLayout = "gird"
+	c.Check(r.Validate(), ErrorMatches, `Invalid category-layout "gird"`)
+
+	r = scopes.NewCategoryRenderer()
+	r.Template.CardSize = "huge"
+	c.Check(r.Validate(), ErrorMatches, `Invalid card-size "huge"`)
+
+	r = scopes.NewCategoryRenderer()
+	r.Template.QuickPreviewType = "image"
+	c.Check(r.Validate(), ErrorMatches, `Invalid quick-preview-type "image"`)
+
+	r = scopes.NewCategoryRenderer()
+	r.AddComponent("titel", "title")
+	c.Check(r.Validate(), ErrorMatches, `Unknown component "titel"`)
+
+	r = scopes.NewCategoryRenderer()
+	r.AddComponent("art", "")
+	c.Check(r.Validate(), ErrorMatches, `Component "art" is not mapped to a field`)
+
+	r = scopes.NewCategoryRenderer()
+	r.SchemaVersion = 2
+	c.Check(r.Validate(), ErrorMatches, `Unsupported schema-version 2`)
+}
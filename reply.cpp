@@ -2,6 +2,7 @@
 #include <cstring>
 #include <iostream>
 
+#include <unity/scopes/OperationInfo.h>
 #include <unity/scopes/PreviewReply.h>
 #include <unity/scopes/SearchReply.h>
 #include <unity/scopes/Version.h>
@@ -43,16 +44,48 @@ void search_reply_register_category(SharedPtrData reply, const StrData id, const
     init_ptr<const Category>(category, cat);
 }
 
+void search_reply_register_category_with_query(SharedPtrData reply, const StrData id, const StrData title, const StrData icon, _CannedQuery *query, const StrData cat_template, SharedPtrData category, char **error) {
+    try {
+        CategoryRenderer renderer;
+        std::string renderer_template = from_gostring(cat_template);
+        if (!renderer_template.empty()) {
+            renderer = CategoryRenderer(renderer_template);
+        }
+        auto cat = get_ptr<SearchReply>(reply)->register_category(from_gostring(id), from_gostring(title), from_gostring(icon), *reinterpret_cast<CannedQuery*>(query), renderer);
+        init_ptr<const Category>(category, cat);
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+    }
+}
+
 void search_reply_register_departments(SharedPtrData reply, SharedPtrData dept) {
     get_ptr<SearchReply>(reply)->register_departments(get_ptr<Department>(dept));
 }
 
-void search_reply_push(SharedPtrData reply, _CategorisedResult *result, char **error) {
+int search_reply_push(SharedPtrData reply, _CategorisedResult *result, char **error) {
+    try {
+        return get_ptr<SearchReply>(reply)->push(*reinterpret_cast<CategorisedResult*>(result));
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+    }
+    return 0;
+}
+
+int search_reply_push_batch(SharedPtrData reply, _CategorisedResult **results, int n_results, char **error) {
+    auto r = get_ptr<SearchReply>(reply);
+    int i = 0;
     try {
-        get_ptr<SearchReply>(reply)->push(*reinterpret_cast<CategorisedResult*>(result));
+        for (; i < n_results; ++i) {
+            // push() returns false once the query is cancelled
+            // or its cardinality has been reached.
+            if (!r->push(*reinterpret_cast<CategorisedResult*>(results[i]))) {
+                break;
+            }
+        }
     } catch (const std::exception &e) {
         *error = strdup(e.what());
     }
+    return i;
 }
 
 void search_reply_push_filters(SharedPtrData reply, const StrData filters_json, const StrData filter_state_json, char **error) {
@@ -76,6 +109,22 @@ void search_reply_push_filters(SharedPtrData reply, const StrData filters_json,
 #endif
 }
 
+void search_reply_info(SharedPtrData reply, int code, const StrData message, char **error) {
+#if UNITY_SCOPES_VERSION_MAJOR == 0 && (UNITY_SCOPES_VERSION_MINOR < 6 || (UNITY_SCOPES_VERSION_MINOR == 6 && UNITY_SCOPES_VERSION_MICRO < 16))
+    std::string errorMessage = "SearchReply.Info() is only available when compiled against libunity-scopes >= 0.6.16";
+    *error = strdup(errorMessage.c_str());
+    std::cerr << errorMessage << std::endl;
+#else
+    try {
+        OperationInfo info(static_cast<OperationInfo::InfoCode>(code),
+                           from_gostring(message));
+        get_ptr<SearchReply>(reply)->info(info);
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+    }
+#endif
+}
+
 void init_preview_reply_ptr(SharedPtrData dest, SharedPtrData src) {
     std::shared_ptr<PreviewReply> reply = get_ptr<PreviewReply>(src);
     init_ptr<PreviewReply>(dest, reply);
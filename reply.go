@@ -5,16 +5,40 @@ package scopes
 import "C"
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"runtime"
+	"sync"
 )
 
+// ErrReplyFinished is returned when sending to a reply after Finished
+// or Error has been called, including when they are called
+// automatically once a query completes or times out.
+var ErrReplyFinished = errors.New("scope reply already finished")
+
 // SearchReply is used to send results of search queries to the client.
+//
+// The methods of SearchReply may be called concurrently from several
+// goroutines.
 type SearchReply struct {
-	r C.SharedPtrData
+	r         C.SharedPtrData
+	cancelled <-chan bool
+	logger    *Logger
+
+	lock     sync.Mutex
+	finished bool
+	counter  pushCounter
+	dedup    *resultDeduper
+
+	validation ResultValidation
+	categories categoryComponents
 }
 
-func makeSearchReply(replyData *C.uintptr_t) *SearchReply {
+func makeSearchReply(replyData *C.uintptr_t, cancelled <-chan bool, logger *Logger, cardinality int) *SearchReply {
 	reply := new(SearchReply)
+	reply.cancelled = cancelled
+	reply.logger = logger
+	reply.counter.cardinality = cardinality
 	runtime.SetFinalizer(reply, finalizeSearchReply)
 	C.init_search_reply_ptr(&reply.r[0], replyData)
 	return reply
@@ -28,8 +52,14 @@ func finalizeSearchReply(reply *SearchReply) {
 // pushed to this reply.
 //
 // This is called automatically if a scope's Search method completes
-// without error.
+// without error.  Calls after the reply has finished are ignored.
 func (reply *SearchReply) Finished() {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return
+	}
+	reply.finished = true
 	C.search_reply_finished(&reply.r[0])
 }
 
@@ -37,12 +67,32 @@ func (reply *SearchReply) Finished() {
 // completed successfully.
 //
 // This is called automatically if a scope's Search method completes
-// with an error.
+// with an error.  Calls after the reply has finished are ignored.
 func (reply *SearchReply) Error(err error) {
 	errString := err.Error()
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return
+	}
+	reply.finished = true
 	C.search_reply_error(&reply.r[0], strData(errString))
 }
 
+// IsCancelled returns true if the search query has been cancelled by
+// the client.  Unlike receiving from the cancellation channel, this
+// does not block.
+func (reply *SearchReply) IsCancelled() bool {
+	return isCancelled(reply.cancelled)
+}
+
+// Logger returns a logger whose entries are tagged with the scope ID,
+// a unique query ID, and the query string, department ID and form
+// factor of the search query.
+func (reply *SearchReply) Logger() *Logger {
+	return reply.logger
+}
+
 // RegisterCategory registers a new results category with the client.
 //
 // The template parameter should either be empty (to use the default
@@ -51,32 +101,358 @@ func (reply *SearchReply) Error(err error) {
 // http://developer.ubuntu.com/api/scopes/sdk-14.10/unity.scopes.CategoryRenderer/#details
 //
 // Categories can be passed to NewCategorisedResult in order to
-// construct search results.
+// construct search results.  Once the reply has finished, the category
+// is no longer sent to the client, but is still returned so that
+// results can be built for it.
 func (reply *SearchReply) RegisterCategory(id, title, icon, template string) *Category {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		cat, err := newUnregisteredCategory(id, title, icon, nil, template)
+		if err != nil {
+			// The category is not displayed anyway.
+			cat, _ = newUnregisteredCategory(id, title, icon, nil, "")
+		}
+		return cat
+	}
 	cat := new(Category)
 	runtime.SetFinalizer(cat, finalizeCategory)
 	C.search_reply_register_category(&reply.r[0], strData(id), strData(title), strData(icon), strData(template), &cat.c[0])
+	reply.categories.register(id, template)
 	return cat
 }
 
+// RegisterCategoryWithQuery registers a new results category with the
+// client, like RegisterCategory.  The query is run when the user asks
+// to see all of the category's results, and is usually a query for
+// the corresponding department.  Unlike RegisterCategory, it returns
+// ErrReplyFinished once the reply has finished.
+func (reply *SearchReply) RegisterCategoryWithQuery(id, title, icon, template string, query *CannedQuery) (*Category, error) {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return nil, ErrReplyFinished
+	}
+	cat := new(Category)
+	runtime.SetFinalizer(cat, finalizeCategory)
+	var errorString *C.char
+	C.search_reply_register_category_with_query(&reply.r[0], strData(id), strData(title), strData(icon), query.q, strData(template), &cat.c[0], &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	reply.categories.register(id, template)
+	return cat, nil
+}
+
+// RegisterCategoryWithRenderer registers a new results category with
+// the client, using the given renderer to display its results.  The
+// renderer is checked with Validate first.  If renderer is nil, the
+// default rendering template is used.
+//
+// Unlike RegisterCategory, it returns ErrReplyFinished once the reply
+// has finished.
+func (reply *SearchReply) RegisterCategoryWithRenderer(id, title, icon string, renderer *CategoryRenderer) (*Category, error) {
+	template := ""
+	if renderer != nil {
+		if err := renderer.Validate(); err != nil {
+			return nil, err
+		}
+		var err error
+		if template, err = renderer.MarshalTemplate(); err != nil {
+			return nil, err
+		}
+	}
+	reply.lock.Lock()
+	finished := reply.finished
+	reply.lock.Unlock()
+	if finished {
+		return nil, ErrReplyFinished
+	}
+	return reply.RegisterCategory(id, title, icon, template), nil
+}
+
 // RegisterDepartments registers the department set to display with
 // the search results.
 //
 // The parent department of the current search should be provided
 // here, with the current department identified among its children by
 // a matching department ID.
-func (reply *SearchReply) RegisterDepartments(parent *Department) {
+//
+// RegisterDepartments returns ErrReplyFinished once the reply has
+// finished.
+func (reply *SearchReply) RegisterDepartments(parent *Department) error {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
 	C.search_reply_register_departments(&reply.r[0], &parent.d[0])
+	return nil
 }
 
 // Push sends a search result to the client.
+//
+// Push returns ErrReplyFinished once the reply has finished,
+// ErrQueryCancelled once the query has been cancelled, and
+// ErrCardinalityReached once the client has received as many
+// results as it asked for through SearchMetadata.Cardinality, or the
+// result's category is full.  Scopes can stop producing results when
+// either is returned, and may return these errors from Search, which
+// still completes normally.
+//
+// If de-duplication has been enabled with SetDeduplication, repeated
+// results are dropped and Push returns nil.  If validation has been
+// enabled with SetResultValidation, invalid results are logged or
+// rejected with a *ResultValidationError.
 func (reply *SearchReply) Push(result *CategorisedResult) error {
+	_, err := reply.push(result, reply.sendResult)
+	return err
+}
+
+// push implements Push, handing the result to send once it has been
+// checked against the settings of the reply.  It reports whether the
+// result was sent, which is not the case for dropped duplicates.
+func (reply *SearchReply) push(result *CategorisedResult, send func(result *CategorisedResult) (bool, error)) (sent bool, err error) {
+	key := reply.dedupKey(result)
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return false, ErrReplyFinished
+	}
+	if reply.IsCancelled() {
+		return false, ErrQueryCancelled
+	}
+	slot, ok, err := reply.reserve(result, key)
+	if !ok {
+		return false, err
+	}
+	pushed, err := send(result)
+	if err != nil {
+		reply.release(slot)
+		return false, err
+	}
+	if !pushed {
+		reply.release(slot)
+		return false, reply.refusedError()
+	}
+	resultsPushed(1)
+	return true, nil
+}
+
+// sendResult passes a result to the scopes runtime, and reports
+// whether it was accepted.
+func (reply *SearchReply) sendResult(result *CategorisedResult) (bool, error) {
 	var errorString *C.char
-	C.search_reply_push(&reply.r[0], result.result, &errorString)
-	return checkError(errorString)
+	pushed := C.search_reply_push(&reply.r[0], result.result, &errorString)
+	if err := checkError(errorString); err != nil {
+		return false, err
+	}
+	return pushed != 0, nil
+}
+
+// PushBatch sends several search results to the client.  It is
+// equivalent to calling Push for each result, but hands them all to
+// the scopes runtime at once, which is considerably cheaper for large
+// result sets.
+//
+// Results that would exceed the cardinality of the reply or of their
+// category are skipped, and ErrCardinalityReached is returned.  If a
+// result can not be pushed, the following results are not sent and
+// the error is returned.  Duplicate results are dropped as by Push.
+//
+// If validation has been enabled with ValidationReject, invalid
+// results are skipped while the others are pushed, and a
+// *BatchValidationError is returned, unless ErrCardinalityReached or
+// an error from the scopes runtime take precedence.
+func (reply *SearchReply) PushBatch(results []*CategorisedResult) error {
+	return reply.pushBatch(results, reply.sendResults)
+}
+
+// pushBatch implements PushBatch, handing the results to send once
+// they have been checked against the settings of the reply.
+func (reply *SearchReply) pushBatch(results []*CategorisedResult, send func(results []*CategorisedResult) (int, error)) error {
+	keys := make([]string, len(results))
+	for i, result := range results {
+		keys[i] = reply.dedupKey(result)
+	}
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
+	if reply.IsCancelled() {
+		return ErrQueryCancelled
+	}
+	var (
+		limitErr error
+		invalid  *BatchValidationError
+	)
+	accepted := make([]*CategorisedResult, 0, len(results))
+	slots := make([]pushSlot, 0, len(results))
+	for i, result := range results {
+		slot, ok, err := reply.reserve(result, keys[i])
+		if !ok {
+			switch {
+			case err == nil:
+				// A dropped duplicate.
+			case err == ErrCardinalityReached:
+				limitErr = err
+			case invalid == nil:
+				invalid = &BatchValidationError{First: err, Count: 1}
+			default:
+				invalid.Count++
+			}
+			continue
+		}
+		accepted = append(accepted, result)
+		slots = append(slots, slot)
+	}
+	if limitErr == nil && invalid != nil {
+		limitErr = invalid
+	}
+	if len(accepted) == 0 {
+		return limitErr
+	}
+	pushed, err := send(accepted)
+	for _, slot := range slots[pushed:] {
+		reply.release(slot)
+	}
+	resultsPushed(pushed)
+	if err != nil {
+		return err
+	}
+	if pushed < len(accepted) {
+		return reply.refusedError()
+	}
+	return limitErr
+}
+
+// sendResults passes results to the scopes runtime, and returns the
+// number it accepted.
+func (reply *SearchReply) sendResults(results []*CategorisedResult) (int, error) {
+	apiResults := make([]*C._CategorisedResult, len(results))
+	for i, result := range results {
+		apiResults[i] = result.result
+	}
+	var errorString *C.char
+	pushed := int(C.search_reply_push_batch(&reply.r[0], &apiResults[0], C.int(len(apiResults)), &errorString))
+	runtime.KeepAlive(results)
+	if err := checkError(errorString); err != nil {
+		return pushed, err
+	}
+	return pushed, nil
+}
+
+// pushSlot records what was reserved for a result about to be
+// pushed, so that it can be released if the push fails.
+type pushSlot struct {
+	categoryId string
+	dedupKey   string
+}
+
+// dedupKey returns the de-duplication key of a result.  It is
+// computed before taking the reply's lock, since the key function is
+// supplied by the scope and may be slow.
+func (reply *SearchReply) dedupKey(result *CategorisedResult) string {
+	reply.lock.Lock()
+	dedup := reply.dedup
+	reply.lock.Unlock()
+	if dedup == nil {
+		return ""
+	}
+	return dedup.key(result)
+}
+
+// reserve checks a result against the de-duplication, cardinality and
+// validation settings of the reply, given its key from dedupKey.  It
+// returns false with a nil error if the result is a duplicate to be
+// dropped.
+//
+// Validation comes last, so that results which would not be sent
+// anyway are neither parsed nor reported.
+func (reply *SearchReply) reserve(result *CategorisedResult, key string) (slot pushSlot, ok bool, err error) {
+	if reply.counter.hasCategoryLimits() || (reply.dedup != nil && reply.dedup.perCategory) || reply.validation != ValidationOff {
+		slot.categoryId = result.CategoryId()
+	}
+	if reply.dedup != nil {
+		if slot.dedupKey, ok = reply.dedup.add(slot.categoryId, key); !ok {
+			return slot, false, nil
+		}
+	}
+	if err := reply.counter.reserve(slot.categoryId); err != nil {
+		if reply.dedup != nil {
+			reply.dedup.remove(slot.dedupKey)
+		}
+		return slot, false, err
+	}
+	if reply.validation != ValidationOff {
+		if err := reply.validate(slot.categoryId, result); err != nil {
+			if reply.validation == ValidationReject {
+				reply.release(slot)
+				return slot, false, err
+			}
+			reply.logger.Warningf("%v", err)
+		}
+	}
+	return slot, true, nil
+}
+
+// validate checks a result against the components of its category,
+// if the category was registered with this reply.
+func (reply *SearchReply) validate(categoryId string, result *CategorisedResult) error {
+	components, ok := reply.categories.lookup(categoryId, reply.logger)
+	if !ok {
+		return nil
+	}
+	data, err := result.attributesJSON()
+	if err != nil {
+		return err
+	}
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return err
+	}
+	return validateResult(categoryId, components, attrs)
+}
+
+// release forgets a result reserved but not pushed.
+func (reply *SearchReply) release(slot pushSlot) {
+	reply.counter.release(slot.categoryId)
+	if reply.dedup != nil {
+		reply.dedup.remove(slot.dedupKey)
+	}
+}
+
+// refusedError returns the error for a result the scopes runtime
+// declined to push.
+func (reply *SearchReply) refusedError() error {
+	if reply.IsCancelled() {
+		return ErrQueryCancelled
+	}
+	return ErrCardinalityReached
+}
+
+// Remaining returns the number of results the client still wants, or
+// -1 if it did not limit the number of results.
+func (reply *SearchReply) Remaining() int {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	return reply.counter.remaining()
+}
+
+// SetCategoryCardinality limits the number of results that may be
+// pushed to the given category.  Once the limit is reached, Push
+// returns ErrCardinalityReached for results of the category.  A limit
+// of zero removes the limit.
+func (reply *SearchReply) SetCategoryCardinality(category *Category, limit int) {
+	categoryId := category.Id()
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	reply.counter.setCategoryLimit(categoryId, limit)
 }
 
 // PushFilters sends the set of filters and their state to the client.
+// It returns ErrReplyFinished once the reply has finished.
 func (reply *SearchReply) PushFilters(filters []Filter, state FilterState) error {
 	var filtersJson, stateJson string
 	filterData := make([]interface{}, len(filters))
@@ -93,18 +469,81 @@ func (reply *SearchReply) PushFilters(filters []Filter, state FilterState) error
 	} else {
 		return err
 	}
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
 	var errorString *C.char
 	C.search_reply_push_filters(&reply.r[0], strData(filtersJson), strData(stateJson), &errorString)
 	return checkError(errorString)
 }
 
+// InfoCode identifies the reason given to the client for missing or
+// incomplete search results.
+type InfoCode int
+
+const (
+	InfoUnknown InfoCode = iota
+	InfoNoInternet
+	InfoPoorInternet
+	InfoNoLocationData
+	InfoInaccurateLocationData
+	InfoResultsIncomplete
+	InfoDefaultSettingsUsed
+	InfoSettingsProblem
+)
+
+var infoCodeNames = []string{
+	"Unknown",
+	"NoInternet",
+	"PoorInternet",
+	"NoLocationData",
+	"InaccurateLocationData",
+	"ResultsIncomplete",
+	"DefaultSettingsUsed",
+	"SettingsProblem",
+}
+
+func (code InfoCode) String() string {
+	if code >= 0 && int(code) < len(infoCodeNames) {
+		return infoCodeNames[code]
+	}
+	return fmt.Sprintf("InfoCode(%d)", int(code))
+}
+
+// Info tells the client why search results may be missing or
+// incomplete, so that it can display a suitable message, for
+// instance when the scope has no internet connection.  The message
+// may be empty.
+func (reply *SearchReply) Info(code InfoCode, message string) error {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
+	var errorString *C.char
+	C.search_reply_info(&reply.r[0], C.int(code), strData(message), &errorString)
+	return checkError(errorString)
+}
+
 // PreviewReply is used to send result previews to the client.
+//
+// The methods of PreviewReply may be called concurrently from several
+// goroutines.
 type PreviewReply struct {
-	r C.SharedPtrData
+	r         C.SharedPtrData
+	cancelled <-chan bool
+	logger    *Logger
+
+	lock     sync.Mutex
+	finished bool
 }
 
-func makePreviewReply(replyData *C.uintptr_t) *PreviewReply {
+func makePreviewReply(replyData *C.uintptr_t, cancelled <-chan bool, logger *Logger) *PreviewReply {
 	reply := new(PreviewReply)
+	reply.cancelled = cancelled
+	reply.logger = logger
 	runtime.SetFinalizer(reply, finalizePreviewReply)
 	C.init_preview_reply_ptr(&reply.r[0], replyData)
 	return reply
@@ -118,8 +557,14 @@ func finalizePreviewReply(reply *PreviewReply) {
 // attributes will be pushed to this reply.
 //
 // This is called automatically if a scope's Preview method completes
-// without error.
+// without error.  Calls after the reply has finished are ignored.
 func (reply *PreviewReply) Finished() {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return
+	}
+	reply.finished = true
 	C.preview_reply_finished(&reply.r[0])
 }
 
@@ -127,13 +572,34 @@ func (reply *PreviewReply) Finished() {
 // be completed successfully.
 //
 // This is called automatically if a scope's Preview method completes
-// with an error.
+// with an error.  Calls after the reply has finished are ignored.
 func (reply *PreviewReply) Error(err error) {
 	errString := err.Error()
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return
+	}
+	reply.finished = true
 	C.preview_reply_error(&reply.r[0], strData(errString))
 }
 
-// PushWidgets sends one or more preview widgets to the client.
+// IsCancelled returns true if the preview request has been cancelled
+// by the client.  Unlike receiving from the cancellation channel, this
+// does not block.
+func (reply *PreviewReply) IsCancelled() bool {
+	return isCancelled(reply.cancelled)
+}
+
+// Logger returns a logger whose entries are tagged with the scope ID,
+// a unique query ID, and the form factor and URI of the result being
+// previewed.
+func (reply *PreviewReply) Logger() *Logger {
+	return reply.logger
+}
+
+// PushWidgets sends one or more preview widgets to the client.  It
+// returns ErrReplyFinished once the reply has finished.
 func (reply *PreviewReply) PushWidgets(widgets ...PreviewWidget) error {
 	widget_data := make([]string, len(widgets))
 	for i, w := range widgets {
@@ -143,6 +609,11 @@ func (reply *PreviewReply) PushWidgets(widgets ...PreviewWidget) error {
 		}
 		widget_data[i] = string(data)
 	}
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
 	var errorString *C.char
 	C.preview_reply_push_widgets(&reply.r[0], joinedStrData(widget_data), &errorString)
 	return checkError(errorString)
@@ -154,12 +625,19 @@ func (reply *PreviewReply) PushWidgets(widgets ...PreviewWidget) error {
 // be mapped by preview widgets.  This allows a widget to be sent to
 // the client early, and then fill it in later when the information is
 // available.
+//
+// PushAttr returns ErrReplyFinished once the reply has finished.
 func (reply *PreviewReply) PushAttr(attr string, value interface{}) error {
 	data, err := json.Marshal(value)
 	if err != nil {
 		return err
 	}
 	json_value := string(data)
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
 	var errorString *C.char
 	C.preview_reply_push_attr(&reply.r[0], strData(attr), strData(json_value), &errorString)
 	return checkError(errorString)
@@ -175,6 +653,11 @@ func (reply *PreviewReply) RegisterLayout(layout ...*ColumnLayout) error {
 	for i, l := range layout {
 		api_layout[i] = l.c
 	}
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	if reply.finished {
+		return ErrReplyFinished
+	}
 	var errorString *C.char
 	C.preview_reply_register_layout(&reply.r[0], &api_layout[0], C.int(len(api_layout)), &errorString)
 	return checkError(errorString)
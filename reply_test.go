@@ -0,0 +1,199 @@
+package scopes_test
+
+import (
+	"errors"
+	"os"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestInfoCodeString(c *C) {
+	c.Check(scopes.InfoNoInternet.String(), Equals, "NoInternet")
+	c.Check(scopes.InfoSettingsProblem.String(), Equals, "SettingsProblem")
+	c.Check(scopes.InfoCode(42).String(), Equals, "InfoCode(42)")
+}
+
+func (s *S) TestSearchReplyFinished(c *C) {
+	reply := scopes.NewFinishedSearchReply()
+	c.Check(reply.Push(nil), Equals, scopes.ErrReplyFinished)
+	c.Check(reply.PushBatch(nil), Equals, scopes.ErrReplyFinished)
+	c.Check(reply.PushFilters(nil, nil), Equals, scopes.ErrReplyFinished)
+	c.Check(reply.Info(scopes.InfoNoInternet, ""), Equals, scopes.ErrReplyFinished)
+	cat := reply.RegisterCategory("cat1", "Category", "", "")
+	c.Assert(cat, NotNil)
+	c.Check(cat.Id(), Equals, "cat1")
+	c.Check(scopes.NewCategorisedResult(cat).CategoryId(), Equals, "cat1")
+	_, err := reply.RegisterCategoryWithRenderer("cat1", "Category", "", nil)
+	c.Check(err, Equals, scopes.ErrReplyFinished)
+
+	dept, err := scopes.NewDepartment("", scopes.NewCannedQuery("scope", "", ""), "All")
+	c.Assert(err, IsNil)
+	c.Check(reply.RegisterDepartments(dept), Equals, scopes.ErrReplyFinished)
+
+	// Completing the reply again is ignored.
+	reply.Finished()
+	reply.Error(errors.New("too late"))
+}
+
+func (s *S) TestPreviewReplyFinished(c *C) {
+	reply := scopes.NewFinishedPreviewReply()
+	c.Check(reply.PushWidgets(), Equals, scopes.ErrReplyFinished)
+	c.Check(reply.PushAttr("attr", "value"), Equals, scopes.ErrReplyFinished)
+	c.Check(reply.RegisterLayout(), Equals, scopes.ErrReplyFinished)
+
+	reply.Finished()
+	reply.Error(errors.New("too late"))
+}
+
+func newURIResult(c *C, category *scopes.Category, uri string) *scopes.CategorisedResult {
+	result := scopes.NewCategorisedResult(category)
+	c.Assert(result.SetURI(uri), IsNil)
+	return result
+}
+
+func (s *S) TestSearchReplyDeduplication(c *C) {
+	cat1, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, "")
+	c.Assert(err, IsNil)
+	cat2, err := scopes.NewTestingCategory("cat2", "Category 2", "", nil, "")
+	c.Assert(err, IsNil)
+
+	reply := scopes.NewTestingSearchReply(3)
+	reply.SetDeduplication(scopes.DedupPerCategory, nil)
+	var sent []*scopes.CategorisedResult
+	c.Check(reply.TestingPush(newURIResult(c, cat1, "uri1"), &sent), IsNil)
+	c.Check(reply.TestingPush(newURIResult(c, cat1, "uri1"), &sent), IsNil)
+	c.Check(reply.TestingPush(newURIResult(c, cat2, "uri1"), &sent), IsNil)
+	c.Assert(sent, HasLen, 2)
+	c.Check(sent[0].CategoryId(), Equals, "cat1")
+	c.Check(sent[1].CategoryId(), Equals, "cat2")
+
+	// Dropped duplicates do not count towards the cardinality.
+	c.Check(reply.TestingPush(newURIResult(c, cat1, "uri2"), &sent), IsNil)
+	c.Check(reply.TestingPush(newURIResult(c, cat1, "uri3"), &sent), Equals, scopes.ErrCardinalityReached)
+	c.Check(sent, HasLen, 3)
+}
+
+func (s *S) TestSearchReplyDeduplicationBatch(c *C) {
+	cat1, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, "")
+	c.Assert(err, IsNil)
+	cat2, err := scopes.NewTestingCategory("cat2", "Category 2", "", nil, "")
+	c.Assert(err, IsNil)
+
+	reply := scopes.NewTestingSearchReply(0)
+	reply.SetDeduplication(scopes.DedupAcrossReply, nil)
+	var sent []*scopes.CategorisedResult
+	c.Check(reply.TestingPush(newURIResult(c, cat1, "uri1"), &sent), IsNil)
+	c.Check(reply.TestingPushBatch([]*scopes.CategorisedResult{
+		newURIResult(c, cat2, "uri1"),
+		newURIResult(c, cat1, "uri2"),
+		newURIResult(c, cat2, "uri2"),
+	}, &sent), IsNil)
+	c.Assert(sent, HasLen, 2)
+	var uri string
+	c.Check(sent[1].Get("uri", &uri), IsNil)
+	c.Check(uri, Equals, "uri2")
+	c.Check(sent[1].CategoryId(), Equals, "cat1")
+
+	// Turning de-duplication off pushes every result.
+	reply.SetDeduplication(scopes.DedupOff, nil)
+	c.Check(reply.TestingPush(newURIResult(c, cat1, "uri1"), &sent), IsNil)
+	c.Check(sent, HasLen, 3)
+}
+
+const (
+	titleTemplate = `{"schema-version":1,"template":{"category-layout":"grid"},"components":{"title":"title"}}`
+	uriTemplate   = `{"schema-version":1,"template":{"category-layout":"grid"},"components":{"title":"uri"}}`
+)
+
+func newTitledResult(c *C, category *scopes.Category, uri string) *scopes.CategorisedResult {
+	result := newURIResult(c, category, uri)
+	c.Assert(result.SetTitle("Title"), IsNil)
+	return result
+}
+
+func (s *S) TestSearchReplyValidationReject(c *C) {
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, titleTemplate)
+	c.Assert(err, IsNil)
+	reply := scopes.NewTestingSearchReply(2)
+	reply.TestingRegisterCategory("cat1", titleTemplate)
+	reply.SetResultValidation(scopes.ValidationReject)
+
+	var sent []*scopes.CategorisedResult
+	c.Check(reply.TestingPush(newTitledResult(c, cat, "uri1"), &sent), IsNil)
+	err = reply.TestingPush(newURIResult(c, cat, "uri2"), &sent)
+	c.Assert(err, FitsTypeOf, &scopes.ResultValidationError{})
+	c.Check(err.(*scopes.ResultValidationError).URI, Equals, "uri2")
+
+	// Rejected results do not count towards the cardinality, and
+	// results beyond it are not validated.
+	c.Check(reply.TestingPush(newTitledResult(c, cat, "uri3"), &sent), IsNil)
+	c.Check(reply.TestingPush(newURIResult(c, cat, "uri4"), &sent), Equals, scopes.ErrCardinalityReached)
+	c.Check(sent, HasLen, 2)
+}
+
+func (s *S) TestSearchReplyValidationRejectBatch(c *C) {
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, titleTemplate)
+	c.Assert(err, IsNil)
+	reply := scopes.NewTestingSearchReply(0)
+	reply.TestingRegisterCategory("cat1", titleTemplate)
+	reply.SetResultValidation(scopes.ValidationReject)
+
+	var sent []*scopes.CategorisedResult
+	err = reply.TestingPushBatch([]*scopes.CategorisedResult{
+		newTitledResult(c, cat, "uri1"),
+		newURIResult(c, cat, "uri2"),
+		newURIResult(c, cat, "uri3"),
+		newTitledResult(c, cat, "uri4"),
+	}, &sent)
+	c.Assert(err, FitsTypeOf, &scopes.BatchValidationError{})
+	batchErr := err.(*scopes.BatchValidationError)
+	c.Check(batchErr.Count, Equals, 2)
+	c.Assert(batchErr.First, FitsTypeOf, &scopes.ResultValidationError{})
+	c.Check(batchErr.First.(*scopes.ResultValidationError).URI, Equals, "uri2")
+	c.Check(err, ErrorMatches, `Invalid result "uri2" .* \(and 1 more invalid results\)`)
+	c.Check(sent, HasLen, 2)
+}
+
+func (s *S) TestSearchReplyValidationLog(c *C) {
+	sink := &recordingSink{}
+	scopes.SetLogSinks(sink)
+	defer scopes.SetLogSinks(scopes.NewTextLogSink(os.Stderr))
+
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, titleTemplate)
+	c.Assert(err, IsNil)
+	reply := scopes.NewTestingSearchReply(0)
+	reply.TestingRegisterCategory("cat1", titleTemplate)
+	reply.SetResultValidation(scopes.ValidationLog)
+	reply.SetDeduplication(scopes.DedupAcrossReply, nil)
+
+	var sent []*scopes.CategorisedResult
+	c.Check(reply.TestingPush(newURIResult(c, cat, "uri1"), &sent), IsNil)
+	c.Check(reply.TestingPushBatch([]*scopes.CategorisedResult{
+		newURIResult(c, cat, "uri1"),
+		newTitledResult(c, cat, "uri2"),
+	}, &sent), IsNil)
+	c.Check(sent, HasLen, 2)
+
+	// The duplicate is dropped before it is validated.
+	c.Assert(sink.entries, HasLen, 1)
+	c.Check(sink.entries[0].Level, Equals, scopes.LogWarning)
+	c.Check(sink.entries[0].Message, Matches, `Invalid result "uri1" in category "cat1": .*`)
+}
+
+func (s *S) TestSearchReplyValidationReregisterCategory(c *C) {
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, titleTemplate)
+	c.Assert(err, IsNil)
+	reply := scopes.NewTestingSearchReply(0)
+	reply.TestingRegisterCategory("cat1", titleTemplate)
+	reply.SetResultValidation(scopes.ValidationReject)
+
+	var sent []*scopes.CategorisedResult
+	err = reply.TestingPush(newURIResult(c, cat, "uri1"), &sent)
+	c.Check(err, FitsTypeOf, &scopes.ResultValidationError{})
+
+	// The new template replaces the old one.
+	reply.TestingRegisterCategory("cat1", uriTemplate)
+	c.Check(reply.TestingPush(newURIResult(c, cat, "uri1"), &sent), IsNil)
+	c.Check(sent, HasLen, 1)
+}
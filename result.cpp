@@ -14,11 +14,70 @@ extern "C" {
 using namespace unity::scopes;
 using namespace gounityscopes::internal;
 
+namespace {
+
+VariantMap result_attrs(Result const &res) {
+    // Result only exposes its attributes through serialize(), which
+    // requires a URI.
+    Result copy(res);
+    if (!copy.contains("uri")) {
+        copy["uri"] = "uri";
+    }
+    VariantMap attrs;
+    for (const auto &attr : copy.serialize()["attrs"].get_dict()) {
+        if (res.contains(attr.first)) {
+            attrs.insert(attr);
+        }
+    }
+    return attrs;
+}
+
+}
+
 _Result *new_categorised_result(SharedPtrData category) {
     auto cat = get_ptr<Category>(category);
     return reinterpret_cast<_CategorisedResult*>(static_cast<Result*>(new CategorisedResult(cat)));
 }
 
+_Result *new_categorised_result_from(SharedPtrData category, _Result *res, char **error) {
+    try {
+        Result const &r = *reinterpret_cast<Result*>(res);
+        std::unique_ptr<CategorisedResult> copy(
+            new CategorisedResult(get_ptr<Category>(category)));
+        for (const auto &attr : result_attrs(r)) {
+            (*copy)[attr.first] = attr.second;
+        }
+        if (r.has_stored_result()) {
+            copy->store(r.retrieve(), !r.direct_activation());
+        } else if (!r.direct_activation()) {
+            copy->set_intercept_activation();
+        }
+        return reinterpret_cast<_Result*>(static_cast<Result*>(copy.release()));
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+}
+
+_Result *categorised_result_clone(_Result *res) {
+    auto r = static_cast<CategorisedResult*>(reinterpret_cast<Result*>(res));
+    return reinterpret_cast<_Result*>(static_cast<Result*>(new CategorisedResult(*r)));
+}
+
+void categorised_result_set_category(_Result *res, SharedPtrData category) {
+    auto cat = get_ptr<const Category>(category);
+    static_cast<CategorisedResult*>(reinterpret_cast<Result*>(res))->set_category(cat);
+}
+
+char *categorised_result_get_category_id(_Result *res) {
+    auto cat = static_cast<CategorisedResult*>(reinterpret_cast<Result*>(res))->category();
+    return strdup(cat->id().c_str());
+}
+
+_Result *result_clone(_Result *res) {
+    return reinterpret_cast<_Result*>(new Result(*reinterpret_cast<Result*>(res)));
+}
+
 void destroy_result(_Result *res) {
     delete reinterpret_cast<Result*>(res);
 }
@@ -44,6 +103,93 @@ void result_set_attr(_Result *res, const StrData attr, const StrData json_value,
     }
 }
 
+void result_set_attrs(_Result *res, const StrData json_attrs, char **error) {
+    try {
+        // Decode every attribute before updating the result.  The
+        // result is updated in place, since copying it into a plain
+        // Result would drop the category of a CategorisedResult.
+        VariantMap attrs = Variant::deserialize_json(from_gostring(json_attrs)).get_dict();
+        Result *r = reinterpret_cast<Result*>(res);
+        for (const auto &attr : attrs) {
+            (*r)[attr.first] = attr.second;
+        }
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+    }
+}
+
 void result_set_intercept_activation(_Result *res) {
     reinterpret_cast<Result*>(res)->set_intercept_activation();
 }
+
+int result_get_intercept_activation(_Result *res) {
+    return !reinterpret_cast<Result*>(res)->direct_activation();
+}
+
+void result_store(_Result *res, _Result *inner, int intercept_activation, char **error) {
+    try {
+        reinterpret_cast<Result*>(res)->store(*reinterpret_cast<Result*>(inner), intercept_activation);
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+    }
+}
+
+int result_has_stored_result(_Result *res) {
+    return reinterpret_cast<Result*>(res)->has_stored_result();
+}
+
+_Result *result_retrieve(_Result *res, char **error) {
+    try {
+        return reinterpret_cast<_Result*>(new Result(reinterpret_cast<Result*>(res)->retrieve()));
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+}
+
+int result_contains(_Result *res, const StrData attr) {
+    return reinterpret_cast<Result*>(res)->contains(from_gostring(attr));
+}
+
+void *result_get_attrs(_Result *res, int *length, char **error) {
+    std::string json_data;
+    try {
+        json_data = Variant(result_attrs(*reinterpret_cast<Result*>(res))).serialize_json();
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+    return as_bytes(json_data, length);
+}
+
+void *result_serialize(_Result *res, int *length, char **error) {
+    std::string json_data;
+    try {
+        json_data = Variant(reinterpret_cast<Result*>(res)->serialize()).serialize_json();
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+    return as_bytes(json_data, length);
+}
+
+namespace {
+
+// DeserializedResult gives access to the protected constructor
+// restoring a Result from its serialized form.
+class DeserializedResult : public Result {
+public:
+    DeserializedResult(VariantMap const &variant_map) : Result(variant_map) {}
+};
+
+}
+
+_Result *result_deserialize(const StrData json_data, char **error) {
+    try {
+        Variant v = Variant::deserialize_json(from_gostring(json_data));
+        return reinterpret_cast<_Result*>(static_cast<Result*>(new DeserializedResult(v.get_dict())));
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+}
@@ -6,6 +6,8 @@ import "C"
 import (
 	"encoding/json"
 	"runtime"
+	"sort"
+	"unsafe"
 )
 
 // Result represents a result from the scope
@@ -46,6 +48,89 @@ func (res *Result) Get(attr string, value interface{}) error {
 	return json.Unmarshal(C.GoBytes(data, length), value)
 }
 
+// Clone returns a copy of the result that can be modified
+// independently of the original.
+func (res *Result) Clone() *Result {
+	return makeResult(C.result_clone(res.result))
+}
+
+// Contains reports whether the result has the named attribute.
+func (res *Result) Contains(attr string) bool {
+	return C.result_contains(res.result, strData(attr)) != 0
+}
+
+// Attributes returns all the attributes of the result, decoded from
+// JSON as by Get into an interface{} value.
+func (res *Result) Attributes() (map[string]interface{}, error) {
+	data, err := res.attributesJSON()
+	if err != nil {
+		return nil, err
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// attributesJSON returns the attributes of the result as a JSON
+// object.
+func (res *Result) attributesJSON() ([]byte, error) {
+	var (
+		length      C.int
+		errorString *C.char
+	)
+	data := C.result_get_attrs(res.result, &length, &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	defer C.free(data)
+	return C.GoBytes(data, length), nil
+}
+
+// Keys returns the sorted names of the result's attributes.
+func (res *Result) Keys() ([]string, error) {
+	attrs, err := res.Attributes()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(attrs))
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Serialize encodes the result, including its activation flags and
+// stored results, as JSON.  Object keys are sorted, so equal results
+// serialize identically.  The result can be restored with
+// DeserializeResult.
+//
+// An error is returned if the result has no "uri" attribute.
+func (res *Result) Serialize() ([]byte, error) {
+	var (
+		length      C.int
+		errorString *C.char
+	)
+	data := C.result_serialize(res.result, &length, &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	defer C.free(data)
+	return C.GoBytes(data, length), nil
+}
+
+// DeserializeResult restores a result encoded with Serialize.
+func DeserializeResult(data []byte) (*Result, error) {
+	var errorString *C.char
+	res := C.result_deserialize(strData(string(data)), &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	return makeResult(res), nil
+}
+
 // Set sets the named result attribute.
 //
 // An error may be returned if the value can not be stored, or if
@@ -62,6 +147,62 @@ func (res *Result) Set(attr string, value interface{}) error {
 	return checkError(errorString)
 }
 
+// SetAttributes sets several result attributes at once.  It is
+// equivalent to calling Set for each attribute, but encodes the
+// values and updates the result in a single operation.
+//
+// If any value can not be stored, an error is returned and the
+// result is left unchanged.
+func (res *Result) SetAttributes(attrs map[string]interface{}) error {
+	data, err := json.Marshal(attrs)
+	if err != nil {
+		return err
+	}
+	var errorString *C.char
+	C.result_set_attrs(res.result, byteData(data), &errorString)
+	return checkError(errorString)
+}
+
+// SetFrom sets result attributes from the fields of a struct, or a
+// pointer to one.  Fields are mapped to attributes with `scope` tags:
+//
+//	type Track struct {
+//	    URI     string   `scope:"uri"`
+//	    Title   string   `scope:"title"`
+//	    Artists []Artist `scope:"artists,omitempty"`
+//	    Cached  bool     `scope:"-"`
+//	}
+//
+// The "omitempty" option skips fields holding the zero value, as with
+// encoding/json.  Fields without a tag are ignored, except for embedded
+// structs, whose fields are treated as fields of the outer struct.
+// Nested structs mapped with `scope` tags become attributes holding a
+// dictionary; other values are encoded as by Set.
+//
+// The attributes are updated atomically, as by SetAttributes.
+func (res *Result) SetFrom(v interface{}) error {
+	attrs, err := marshalAttributes(v)
+	if err != nil {
+		return err
+	}
+	return res.SetAttributes(attrs)
+}
+
+// DecodeInto sets the fields of the struct pointed to by v from the
+// result attributes, using the `scope` tags described for SetFrom.
+// Fields whose attribute is not set on the result are left untouched.
+func (res *Result) DecodeInto(v interface{}) error {
+	data, err := res.attributesJSON()
+	if err != nil {
+		return err
+	}
+	var attrs map[string]json.RawMessage
+	if err := json.Unmarshal(data, &attrs); err != nil {
+		return err
+	}
+	return decodeAttributes(attrs, v)
+}
+
 // SetInterceptActivation marks this result as needing custom activation handling.
 //
 // By default, results are activated by the client directly (e.g. by
@@ -72,6 +213,47 @@ func (res *Result) SetInterceptActivation() {
 	C.result_set_intercept_activation(res.result)
 }
 
+// StoreResult stores a copy of inner in the result.  Aggregator scopes
+// use it to wrap the results of their children, so that the preview
+// and activation of the result can still be handled by the child.
+//
+// If interceptActivation is false, the client sends the preview and
+// activation requests for the result to the scope that produced inner.
+// Otherwise they are sent to this scope, which may forward them with
+// ForwardPreview and ForwardActivation.
+func (res *Result) StoreResult(inner *Result, interceptActivation bool) error {
+	var intercept C.int
+	if interceptActivation {
+		intercept = 1
+	}
+	var errorString *C.char
+	C.result_store(res.result, inner.result, intercept, &errorString)
+	return checkError(errorString)
+}
+
+// HasStoredResult reports whether a result has been stored in this
+// one with StoreResult.
+func (res *Result) HasStoredResult() bool {
+	return C.result_has_stored_result(res.result) != 0
+}
+
+// RetrieveStoredResult returns the result stored with StoreResult.  An
+// error is returned if there is no stored result.
+func (res *Result) RetrieveStoredResult() (*Result, error) {
+	var errorString *C.char
+	inner := C.result_retrieve(res.result, &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	return makeResult(inner), nil
+}
+
+// InterceptActivation reports whether SetInterceptActivation has been
+// called on the result.
+func (res *Result) InterceptActivation() bool {
+	return C.result_get_intercept_activation(res.result) != 0
+}
+
 // SetURI sets the "uri" attribute of the result.
 func (res *Result) SetURI(uri string) error {
 	return res.Set("uri", uri)
@@ -141,3 +323,43 @@ func NewCategorisedResult(category *Category) *CategorisedResult {
 func finalizeCategorisedResult(res *CategorisedResult) {
 	finalizeResult(&res.Result)
 }
+
+func makeCategorisedResult(res *C._Result) *CategorisedResult {
+	result := new(CategorisedResult)
+	runtime.SetFinalizer(result, finalizeCategorisedResult)
+	result.result = res
+	return result
+}
+
+// NewCategorisedResultFrom creates a new result in the given category
+// holding a copy of the attributes of result, along with its stored
+// result and activation flag.  It can be used to push the same data
+// to several categories; a CategorisedResult can be passed as
+// &res.Result.
+func NewCategorisedResultFrom(category *Category, result *Result) (*CategorisedResult, error) {
+	var errorString *C.char
+	res := C.new_categorised_result_from(&category.c[0], result.result, &errorString)
+	if err := checkError(errorString); err != nil {
+		return nil, err
+	}
+	return makeCategorisedResult(res), nil
+}
+
+// Clone returns a copy of the result in the same category.  The copy
+// can be modified and pushed independently of the original, including
+// from another goroutine.
+func (res *CategorisedResult) Clone() *CategorisedResult {
+	return makeCategorisedResult(C.categorised_result_clone(res.result))
+}
+
+// CategoryId returns the ID of the result's category.
+func (res *CategorisedResult) CategoryId() string {
+	s := C.categorised_result_get_category_id(res.result)
+	defer C.free(unsafe.Pointer(s))
+	return C.GoString(s)
+}
+
+// setCategory moves the result to another category.
+func (res *CategorisedResult) setCategory(category *Category) {
+	C.categorised_result_set_category(res.result, &category.c[0])
+}
@@ -1,6 +1,8 @@
 package scopes_test
 
 import (
+	"context"
+
 	. "gopkg.in/check.v1"
 	"launchpad.net/go-unityscopes/v2"
 )
@@ -97,3 +99,246 @@ func (s *S) TestResultGetBadValue(c *C) {
 	var attr string
 	c.Check(r.Get("bad_attribute", &attr), Not(Equals), nil)
 }
+
+func (s *S) TestResultSetAttributes(c *C) {
+	r := scopes.NewTestingResult()
+	c.Check(r.SetAttributes(map[string]interface{}{
+		"uri":   "http://example.com",
+		"title": "The title",
+		"count": 42,
+	}), IsNil)
+	c.Check(r.URI(), Equals, "http://example.com")
+	c.Check(r.Title(), Equals, "The title")
+
+	var count int
+	c.Check(r.Get("count", &count), IsNil)
+	c.Check(count, Equals, 42)
+}
+
+func (s *S) TestCategorisedResultSetAttributes(c *C) {
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, "")
+	c.Assert(err, IsNil)
+	r := scopes.NewCategorisedResult(cat)
+	c.Check(r.SetAttributes(map[string]interface{}{
+		"uri":   "http://example.com",
+		"title": "The title",
+	}), IsNil)
+	c.Check(r.CategoryId(), Equals, "cat1")
+	c.Check(r.URI(), Equals, "http://example.com")
+	c.Check(r.Title(), Equals, "The title")
+
+	// The category also survives updates through SetFrom.
+	c.Check(r.SetFrom(struct {
+		Title string `scope:"title"`
+	}{"Other title"}), IsNil)
+	c.Check(r.CategoryId(), Equals, "cat1")
+	c.Check(r.Title(), Equals, "Other title")
+	c.Check(r.Clone().CategoryId(), Equals, "cat1")
+}
+
+func (s *S) TestResultSetAttributesUnserializable(c *C) {
+	r := scopes.NewTestingResult()
+	err := r.SetAttributes(map[string]interface{}{
+		"title": "The title",
+		"bad":   &unserializable{},
+	})
+	c.Check(err, Not(IsNil))
+	c.Check(r.Title(), Equals, "")
+}
+
+func (s *S) TestResultAttributes(c *C) {
+	r := scopes.NewTestingResult()
+	c.Check(r.Contains("title"), Equals, false)
+	keys, err := r.Keys()
+	c.Check(err, IsNil)
+	c.Check(keys, HasLen, 0)
+
+	c.Check(r.SetAttributes(map[string]interface{}{
+		"title": "The title",
+		"count": 42,
+	}), IsNil)
+	c.Check(r.Contains("title"), Equals, true)
+	c.Check(r.Contains("uri"), Equals, false)
+	keys, err = r.Keys()
+	c.Check(err, IsNil)
+	c.Check(keys, DeepEquals, []string{"count", "title"})
+
+	attrs, err := r.Attributes()
+	c.Check(err, IsNil)
+	c.Check(attrs, DeepEquals, map[string]interface{}{
+		"title": "The title",
+		"count": float64(42),
+	})
+}
+
+func (s *S) TestResultInterceptActivation(c *C) {
+	r := scopes.NewTestingResult()
+	c.Check(r.InterceptActivation(), Equals, false)
+	r.SetInterceptActivation()
+	c.Check(r.InterceptActivation(), Equals, true)
+}
+
+func (s *S) TestResultSerialize(c *C) {
+	r := scopes.NewTestingResult()
+	_, err := r.Serialize()
+	c.Check(err, Not(IsNil))
+
+	c.Check(r.SetURI("http://example.com"), IsNil)
+	c.Check(r.SetTitle("The title"), IsNil)
+	r.SetInterceptActivation()
+	data, err := r.Serialize()
+	c.Assert(err, IsNil)
+
+	r2, err := scopes.DeserializeResult(data)
+	c.Assert(err, IsNil)
+	c.Check(r2.URI(), Equals, "http://example.com")
+	c.Check(r2.Title(), Equals, "The title")
+	c.Check(r2.InterceptActivation(), Equals, true)
+
+	data2, err := r2.Serialize()
+	c.Check(err, IsNil)
+	c.Check(string(data2), Equals, string(data))
+}
+
+func (s *S) TestDeserializeResultInvalid(c *C) {
+	_, err := scopes.DeserializeResult([]byte("not json"))
+	c.Check(err, Not(IsNil))
+}
+
+func (s *S) TestResultSetFromDecodeInto(c *C) {
+	type Track struct {
+		URI      string   `scope:"uri"`
+		Title    string   `scope:"title"`
+		Tags     []string `scope:"tags,omitempty"`
+		Duration int      `scope:"duration"`
+	}
+
+	r := scopes.NewTestingResult()
+	c.Check(r.SetFrom(&Track{URI: "http://example.com", Title: "The title", Duration: 42}), IsNil)
+	c.Check(r.URI(), Equals, "http://example.com")
+	c.Check(r.Contains("tags"), Equals, false)
+
+	var track Track
+	c.Check(r.DecodeInto(&track), IsNil)
+	c.Check(track, DeepEquals, Track{URI: "http://example.com", Title: "The title", Duration: 42})
+}
+
+func (s *S) TestResultStoreResult(c *C) {
+	inner := scopes.NewTestingResult()
+	c.Check(inner.SetURI("http://example.com/inner"), IsNil)
+	c.Check(inner.SetTitle("Inner"), IsNil)
+
+	r := scopes.NewTestingResult()
+	c.Check(r.HasStoredResult(), Equals, false)
+	_, err := r.RetrieveStoredResult()
+	c.Check(err, Not(IsNil))
+
+	c.Check(r.SetURI("http://example.com/outer"), IsNil)
+	c.Check(r.StoreResult(inner, true), IsNil)
+	c.Check(r.HasStoredResult(), Equals, true)
+	c.Check(r.InterceptActivation(), Equals, true)
+
+	stored, err := r.RetrieveStoredResult()
+	c.Assert(err, IsNil)
+	c.Check(stored.URI(), Equals, "http://example.com/inner")
+	c.Check(stored.Title(), Equals, "Inner")
+}
+
+func (s *S) TestResultForward(c *C) {
+	r := scopes.NewTestingResult()
+	c.Check(r.SetURI("http://example.com/outer"), IsNil)
+	reply := scopes.NewFinishedPreviewReply()
+	c.Check(r.ForwardPreview(context.Background(), nil, reply), Equals, scopes.ErrNoStoredResult)
+	_, err := r.ForwardActivation(context.Background(), nil)
+	c.Check(err, Equals, scopes.ErrNoStoredResult)
+
+	inner := scopes.NewTestingResult()
+	c.Check(inner.SetURI("http://example.com/inner"), IsNil)
+	c.Check(r.StoreResult(inner, true), IsNil)
+	c.Check(r.ForwardPreview(context.Background(), nil, reply), Equals, scopes.ErrReplyFinished)
+}
+
+func (s *S) TestResultClone(c *C) {
+	r := scopes.NewTestingResult()
+	c.Check(r.SetURI("http://example.com"), IsNil)
+	c.Check(r.SetTitle("The title"), IsNil)
+	r.SetInterceptActivation()
+
+	clone := r.Clone()
+	c.Check(clone.URI(), Equals, "http://example.com")
+	c.Check(clone.Title(), Equals, "The title")
+	c.Check(clone.InterceptActivation(), Equals, true)
+
+	// The copies are independent.
+	c.Check(clone.SetTitle("Changed"), IsNil)
+	c.Check(r.Title(), Equals, "The title")
+	c.Check(r.SetURI("http://example.com/other"), IsNil)
+	c.Check(clone.URI(), Equals, "http://example.com")
+}
+
+func (s *S) TestNewCategorisedResultFrom(c *C) {
+	cat1, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, "")
+	c.Assert(err, IsNil)
+	cat2, err := scopes.NewTestingCategory("cat2", "Category 2", "", nil, "")
+	c.Assert(err, IsNil)
+
+	inner := scopes.NewTestingResult()
+	c.Check(inner.SetURI("http://example.com/inner"), IsNil)
+	original := scopes.NewCategorisedResult(cat1)
+	c.Check(original.SetURI("http://example.com"), IsNil)
+	c.Check(original.SetTitle("The title"), IsNil)
+	c.Check(original.StoreResult(inner, true), IsNil)
+
+	// The copy is moved to the given category.
+	r, err := scopes.NewCategorisedResultFrom(cat2, &original.Result)
+	c.Assert(err, IsNil)
+	c.Check(r.CategoryId(), Equals, "cat2")
+	c.Check(original.CategoryId(), Equals, "cat1")
+	c.Check(r.URI(), Equals, "http://example.com")
+	c.Check(r.Title(), Equals, "The title")
+	c.Check(r.InterceptActivation(), Equals, true)
+	c.Check(r.HasStoredResult(), Equals, true)
+	stored, err := r.RetrieveStoredResult()
+	c.Assert(err, IsNil)
+	c.Check(stored.URI(), Equals, "http://example.com/inner")
+
+	// A copy in the same category keeps it.
+	r, err = scopes.NewCategorisedResultFrom(cat1, &original.Result)
+	c.Assert(err, IsNil)
+	c.Check(r.CategoryId(), Equals, "cat1")
+
+	// Plain results can be copied too.
+	plain := scopes.NewTestingResult()
+	c.Check(plain.SetURI("http://example.com/plain"), IsNil)
+	r, err = scopes.NewCategorisedResultFrom(cat1, plain)
+	c.Assert(err, IsNil)
+	c.Check(r.CategoryId(), Equals, "cat1")
+	c.Check(r.URI(), Equals, "http://example.com/plain")
+	c.Check(r.HasStoredResult(), Equals, false)
+	c.Check(r.InterceptActivation(), Equals, false)
+}
+
+func (s *S) TestCategorisedResultClone(c *C) {
+	cat, err := scopes.NewTestingCategory("cat1", "Category 1", "", nil, "")
+	c.Assert(err, IsNil)
+
+	inner := scopes.NewTestingResult()
+	c.Check(inner.SetURI("http://example.com/inner"), IsNil)
+	r := scopes.NewCategorisedResult(cat)
+	c.Check(r.SetURI("http://example.com"), IsNil)
+	c.Check(r.StoreResult(inner, false), IsNil)
+	r.SetInterceptActivation()
+
+	clone := r.Clone()
+	c.Check(clone.CategoryId(), Equals, "cat1")
+	c.Check(clone.URI(), Equals, "http://example.com")
+	c.Check(clone.InterceptActivation(), Equals, true)
+	c.Check(clone.HasStoredResult(), Equals, true)
+	stored, err := clone.RetrieveStoredResult()
+	c.Assert(err, IsNil)
+	c.Check(stored.URI(), Equals, "http://example.com/inner")
+
+	// The copies are independent.
+	c.Check(clone.SetURI("http://example.com/other"), IsNil)
+	c.Check(r.URI(), Equals, "http://example.com")
+}
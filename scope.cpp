@@ -10,12 +10,27 @@ using namespace unity::scopes;
 ScopeAdapter::ScopeAdapter(GoInterface goscope) : goscope(goscope) {
 }
 
-void ScopeAdapter::start(std::string const &) {
+void ScopeAdapter::start(std::string const &scope_id) {
     setScopeBase(goscope, reinterpret_cast<_ScopeBase*>(this));
+    char *error = nullptr;
+    callScopeStart(goscope, const_cast<char*>(scope_id.c_str()), &error);
+    if (error != nullptr) {
+        const std::string message(error);
+        free(error);
+        setScopeBase(goscope, nullptr);
+        throw std::runtime_error(message);
+    }
 }
 
 void ScopeAdapter::stop() {
+    char *error = nullptr;
+    callScopeStop(goscope, &error);
     setScopeBase(goscope, nullptr);
+    if (error != nullptr) {
+        const std::string message(error);
+        free(error);
+        throw std::runtime_error(message);
+    }
 }
 
 SearchQueryBase::UPtr ScopeAdapter::search(CannedQuery const &q,
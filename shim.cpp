@@ -1,4 +1,7 @@
 #include <cstring>
+#include <iostream>
+#include <memory>
+#include <mutex>
 
 #include <unity/scopes/Category.h>
 #include <unity/scopes/Runtime.h>
@@ -14,12 +17,46 @@ extern "C" {
 using namespace unity::scopes;
 using namespace gounityscopes::internal;
 
+namespace {
+
+// The runtime of the running scope, so that stop_scope() can shut it
+// down from another thread.
+std::mutex current_runtime_mutex;
+std::shared_ptr<Runtime> current_runtime;
+// Set by stop_scope() when no scope is running yet, so that the next
+// scope is stopped as soon as its runtime has been created.
+bool stop_requested = false;
+
+class CurrentRuntime {
+public:
+    CurrentRuntime(std::shared_ptr<Runtime> const &runtime) {
+        std::lock_guard<std::mutex> lock(current_runtime_mutex);
+        current_runtime = runtime;
+        stopped = stop_requested;
+        stop_requested = false;
+    }
+    ~CurrentRuntime() {
+        std::lock_guard<std::mutex> lock(current_runtime_mutex);
+        current_runtime = nullptr;
+    }
+
+    // Whether stop_scope() was called before the runtime was set.
+    bool stopped;
+};
+
+}
+
 void run_scope(const StrData scope_name, const StrData runtime_config,
                const StrData scope_config, void *pointer_to_iface,
                char **error) {
     try {
-        auto runtime = Runtime::create_scope_runtime(
-            from_gostring(scope_name), from_gostring(runtime_config));
+        std::shared_ptr<Runtime> runtime(Runtime::create_scope_runtime(
+            from_gostring(scope_name), from_gostring(runtime_config)));
+        CurrentRuntime current(runtime);
+        if (current.stopped) {
+            runtime->destroy();
+            return;
+        }
         ScopeAdapter scope(*reinterpret_cast<GoInterface*>(pointer_to_iface));
         runtime->run_scope(&scope, from_gostring(scope_config));
     } catch (const std::exception &e) {
@@ -27,6 +64,26 @@ void run_scope(const StrData scope_name, const StrData runtime_config,
     }
 }
 
+void stop_scope() {
+    // Destroying the runtime makes run_scope() return, which clears
+    // current_runtime, so the lock must not be held meanwhile.
+    std::shared_ptr<Runtime> runtime;
+    {
+        std::lock_guard<std::mutex> lock(current_runtime_mutex);
+        runtime = current_runtime;
+        if (!runtime) {
+            stop_requested = true;
+        }
+    }
+    if (runtime) {
+        try {
+            runtime->destroy();
+        } catch (const std::exception &e) {
+            std::cerr << "Error stopping scope: " << e.what() << std::endl;
+        }
+    }
+}
+
 char *scope_base_scope_directory(_ScopeBase *scope) {
     ScopeBase *s = reinterpret_cast<ScopeBase*>(scope);
     return strdup(s->scope_directory().c_str());
@@ -48,6 +105,10 @@ void *scope_base_settings(_ScopeBase *scope, int *length) {
     return as_bytes(settings.serialize_json(), length);
 }
 
+void init_category_ptr(SharedPtrData dest, SharedPtrData src) {
+    init_ptr<const Category>(dest, get_ptr<const Category>(src));
+}
+
 void destroy_category_ptr(SharedPtrData data) {
     destroy_ptr<const Category>(data);
 }
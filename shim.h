@@ -25,6 +25,8 @@ typedef struct _ScopeMetadata _ScopeMetadata;
 typedef struct _QueryMetadata _QueryMetadata;
 typedef struct _ColumnLayout _ColumnLayout;
 typedef struct _ChildScope _ChildScope;
+typedef struct _SubSearch _SubSearch;
+typedef struct _Forward _Forward;
 typedef void _ScopeBase;
 typedef struct _GoString _GoString;
 
@@ -33,6 +35,7 @@ typedef struct _ActivationResponse _ActivationResponse;
 void run_scope(const StrData scope_name, const StrData runtime_config,
                const StrData scope_config, void *pointer_to_iface,
                char **error);
+void stop_scope(void);
 
 /* ScopeBase objects */
 char *scope_base_scope_directory(_ScopeBase *scope);
@@ -46,8 +49,24 @@ _ChildScope **list_child_scopes(_ScopeBase *scope, int *n_scopes);
 _ChildScope *new_child_scope(const StrData id, _ScopeMetadata *metadata, int enabled, const StrData keyword_list);
 void destroy_child_scope(_ChildScope *childscope);
 char *child_scope_get_id(_ChildScope *childscope);
+int child_scope_get_enabled(_ChildScope *childscope);
+void *child_scope_get_keywords(_ChildScope *childscope, int *length);
+_ScopeMetadata *child_scope_get_metadata(_ChildScope *childscope);
 void set_child_scopes_list(void *child_scopes_list, _ChildScope **source_child_scopes, int length);
 
+/* SubSearch objects */
+_SubSearch *scope_base_subsearch(_ScopeBase *scope, const StrData scope_id, _CannedQuery *query, _SearchMetadata *metadata, uintptr_t handle, char **error);
+void sub_search_cancel(_SubSearch *search);
+void destroy_sub_search(_SubSearch *search);
+
+/* Queries forwarded to the scope of a stored result */
+_Forward *result_forward_preview(_Result *res, _ActionMetadata *metadata, SharedPtrData reply, char **error);
+_Forward *result_forward_activation(_Result *res, _ActionMetadata *metadata, char **error);
+int forward_wait(_Forward *forward, char **message);
+void forward_cancel(_Forward *forward);
+_ActivationResponse *forward_get_activation_response(_Forward *forward);
+void destroy_forward(_Forward *forward);
+
 /* SearchReply objects */
 void init_search_reply_ptr(SharedPtrData dest, SharedPtrData src);
 void destroy_search_reply_ptr(SharedPtrData data);
@@ -55,9 +74,12 @@ void destroy_search_reply_ptr(SharedPtrData data);
 void search_reply_finished(SharedPtrData reply);
 void search_reply_error(SharedPtrData reply, const StrData err_string);
 void search_reply_register_category(SharedPtrData reply, const StrData id, const StrData title, const StrData icon, const StrData cat_template, SharedPtrData category);
+void search_reply_register_category_with_query(SharedPtrData reply, const StrData id, const StrData title, const StrData icon, _CannedQuery *query, const StrData cat_template, SharedPtrData category, char **error);
 void search_reply_register_departments(SharedPtrData reply, SharedPtrData dept);
-void search_reply_push(SharedPtrData reply, _CategorisedResult *result, char **error);
+int search_reply_push(SharedPtrData reply, _CategorisedResult *result, char **error);
+int search_reply_push_batch(SharedPtrData reply, _CategorisedResult **results, int n_results, char **error);
 void search_reply_push_filters(SharedPtrData reply, const StrData filters_json, const StrData filter_state_json, char **error);
+void search_reply_info(SharedPtrData reply, int code, const StrData message, char **error);
 
 /* PreviewReply objects */
 void init_preview_reply_ptr(SharedPtrData dest, SharedPtrData src);
@@ -81,16 +103,37 @@ void canned_query_set_query_string(_CannedQuery *query, const StrData query_str)
 char *canned_query_to_uri(_CannedQuery *query);
 
 /* Category objects */
+void init_category_ptr(SharedPtrData dest, SharedPtrData src);
 void destroy_category_ptr(SharedPtrData data);
+char *category_get_id(SharedPtrData cat);
+char *category_get_title(SharedPtrData cat);
+char *category_get_icon(SharedPtrData cat);
+char *category_get_renderer_template(SharedPtrData cat);
+_CannedQuery *category_get_query(SharedPtrData cat);
+void new_unregistered_category(const StrData id, const StrData title, const StrData icon, _CannedQuery *query, const StrData cat_template, SharedPtrData category, char **error);
 
 /* CategorisedResult objects */
 _Result *new_categorised_result(SharedPtrData category);
+_Result *new_categorised_result_from(SharedPtrData category, _Result *res, char **error);
+_Result *categorised_result_clone(_Result *res);
+void categorised_result_set_category(_Result *res, SharedPtrData category);
+char *categorised_result_get_category_id(_Result *res);
+_Result *result_clone(_Result *res);
 void destroy_result(_Result *res);
 
 /* Result objects */
 void *result_get_attr(_Result *res, const StrData attr, int *length, char **error);
 void result_set_attr(_Result *res, const StrData attr, const StrData json_value, char **error);
+void result_set_attrs(_Result *res, const StrData json_attrs, char **error);
 void result_set_intercept_activation(_Result *res);
+int result_get_intercept_activation(_Result *res);
+void result_store(_Result *res, _Result *inner, int intercept_activation, char **error);
+int result_has_stored_result(_Result *res);
+_Result *result_retrieve(_Result *res, char **error);
+int result_contains(_Result *res, const StrData attr);
+void *result_get_attrs(_Result *res, int *length, char **error);
+void *result_serialize(_Result *res, int *length, char **error);
+_Result *result_deserialize(const StrData json_data, char **error);
 
 /* Department objects */
 void init_department_ptr(SharedPtrData dest, SharedPtrData src);
@@ -143,6 +186,9 @@ void activation_response_init_query(_ActivationResponse *response, _CannedQuery
 void activation_response_init_update_result(_ActivationResponse *response, _Result *result);
 void activation_response_init_update_preview(_ActivationResponse *response, const StrData widget_list, char **error);
 void activation_response_set_scope_data(_ActivationResponse *response, char *json_data, int json_data_length, char **error);
+int activation_response_get_status(_ActivationResponse *response);
+void activation_response_copy(_ActivationResponse *dest, _ActivationResponse *src);
+void destroy_activation_response(_ActivationResponse *response);
 
 /* ColumnLayout objects */
 _ColumnLayout *new_column_layout(int num_columns);
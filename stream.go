@@ -0,0 +1,60 @@
+package scopes
+
+import (
+	"context"
+)
+
+// PushFrom pushes the results received from a channel until it is
+// closed, and returns the number of results delivered to the client.
+// Duplicates dropped as described by SetDeduplication are not
+// counted.
+//
+// It stops early when ctx is done, when the query is cancelled by the
+// client, or when a push fails, returning ctx.Err(),
+// ErrQueryCancelled or the error from Push respectively.  Since the
+// remaining results are not received, the producer should stop
+// sending once ctx is done; scopes that implement Search rather than
+// SearchContext can derive such a context with context.WithCancel and
+// cancel it after PushFrom returns.  ErrCardinalityReached and
+// ErrQueryCancelled mean that the client wants no more results, not
+// that the search failed:
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//	results := make(chan *scopes.CategorisedResult)
+//	go produce(ctx, results)
+//	_, err := reply.PushFrom(ctx, results)
+//	if err == scopes.ErrCardinalityReached || err == scopes.ErrQueryCancelled {
+//		return nil
+//	}
+//	return err
+func (reply *SearchReply) PushFrom(ctx context.Context, results <-chan *CategorisedResult) (int, error) {
+	return pushFrom(ctx, reply.cancelled, results, func(result *CategorisedResult) (bool, error) {
+		return reply.push(result, reply.sendResult)
+	})
+}
+
+// pushFrom implements PushFrom.  The push function reports whether
+// each result was delivered.
+func pushFrom(ctx context.Context, cancelled <-chan bool, results <-chan *CategorisedResult, push func(*CategorisedResult) (bool, error)) (int, error) {
+	pushed := 0
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return pushed, nil
+			}
+			sent, err := push(result)
+			if err != nil {
+				return pushed, err
+			}
+			if sent {
+				pushed++
+			}
+		case <-ctx.Done():
+			return pushed, ctx.Err()
+		case <-cancelled:
+			return pushed, ErrQueryCancelled
+		}
+	}
+}
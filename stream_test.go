@@ -0,0 +1,87 @@
+package scopes_test
+
+import (
+	"context"
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func sendResults(n int) chan *scopes.CategorisedResult {
+	results := make(chan *scopes.CategorisedResult, n)
+	for i := 0; i < n; i++ {
+		results <- new(scopes.CategorisedResult)
+	}
+	return results
+}
+
+func (s *S) TestPushFromDrainsChannel(c *C) {
+	results := sendResults(3)
+	close(results)
+	var pushed []*scopes.CategorisedResult
+	n, err := scopes.PushFrom(context.Background(), nil, results, func(result *scopes.CategorisedResult) (bool, error) {
+		pushed = append(pushed, result)
+		return true, nil
+	})
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 3)
+	c.Check(pushed, HasLen, 3)
+}
+
+func (s *S) TestPushFromPushError(c *C) {
+	results := sendResults(3)
+	close(results)
+	pushErr := errors.New("push failed")
+	calls := 0
+	n, err := scopes.PushFrom(context.Background(), nil, results, func(result *scopes.CategorisedResult) (bool, error) {
+		calls++
+		if calls == 2 {
+			return false, pushErr
+		}
+		return true, nil
+	})
+	c.Check(err, Equals, pushErr)
+	c.Check(n, Equals, 1)
+	c.Check(calls, Equals, 2)
+}
+
+func (s *S) TestPushFromContextDone(c *C) {
+	results := sendResults(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	n, err := scopes.PushFrom(ctx, nil, results, func(result *scopes.CategorisedResult) (bool, error) {
+		// The channel is never closed, so only the
+		// cancellation ends the loop.
+		cancel()
+		return true, nil
+	})
+	c.Check(err, Equals, context.Canceled)
+	c.Check(n, Equals, 1)
+}
+
+func (s *S) TestPushFromQueryCancelled(c *C) {
+	cancelled := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(cancelled)
+	scopes.SendCancelChannel(cancelled)
+	results := make(chan *scopes.CategorisedResult)
+	n, err := scopes.PushFrom(context.Background(), cancelled, results, func(result *scopes.CategorisedResult) (bool, error) {
+		c.Error("unexpected push")
+		return false, nil
+	})
+	c.Check(err, Equals, scopes.ErrQueryCancelled)
+	c.Check(n, Equals, 0)
+}
+
+func (s *S) TestPushFromDroppedResults(c *C) {
+	results := sendResults(3)
+	close(results)
+	calls := 0
+	n, err := scopes.PushFrom(context.Background(), nil, results, func(result *scopes.CategorisedResult) (bool, error) {
+		// The second result is a dropped duplicate.
+		calls++
+		return calls != 2, nil
+	})
+	c.Check(err, IsNil)
+	c.Check(n, Equals, 2)
+	c.Check(calls, Equals, 3)
+}
@@ -0,0 +1,110 @@
+#include <cstring>
+#include <stdexcept>
+
+#include <unity/scopes/CannedQuery.h>
+#include <unity/scopes/CategorisedResult.h>
+#include <unity/scopes/CompletionDetails.h>
+#include <unity/scopes/FilterBase.h>
+#include <unity/scopes/FilterState.h>
+#include <unity/scopes/QueryCtrl.h>
+#include <unity/scopes/Registry.h>
+#include <unity/scopes/ScopeBase.h>
+#include <unity/scopes/SearchListenerBase.h>
+#include <unity/scopes/SearchMetadata.h>
+#include <unity/scopes/Variant.h>
+
+extern "C" {
+#include "_cgo_export.h"
+}
+#include "helpers.h"
+#include "smartptr_helper.h"
+
+using namespace unity::scopes;
+using namespace gounityscopes::internal;
+
+namespace {
+
+// SubSearchListener forwards the replies of a child scope to the Go
+// SubSearch identified by handle.
+class SubSearchListener : public SearchListenerBase {
+public:
+    SubSearchListener(uintptr_t handle) : handle(handle) {}
+
+    virtual void push(CategorisedResult result) override {
+        subSearchPushResult(handle, reinterpret_cast<_Result*>(
+            static_cast<Result*>(new CategorisedResult(std::move(result)))));
+    }
+
+    virtual void push(Category::SCPtr const &category) override {
+        Category::SCPtr cat(category);
+        subSearchPushCategory(handle, reinterpret_cast<uintptr_t*>(&cat));
+    }
+
+    virtual void push(Department::SCPtr const &parent) override {
+        auto dept = std::const_pointer_cast<Department>(parent);
+        subSearchPushDepartment(handle, reinterpret_cast<uintptr_t*>(&dept));
+    }
+
+    virtual void push(Filters const &filters, FilterState const &filter_state) override {
+        VariantArray filter_list;
+        for (const auto &f : filters) {
+            filter_list.push_back(Variant(f->serialize()));
+        }
+        std::string filters_json = Variant(filter_list).serialize_json();
+        std::string state_json = Variant(filter_state.serialize()).serialize_json();
+        subSearchPushFilters(handle,
+                             const_cast<char*>(filters_json.c_str()),
+                             const_cast<char*>(state_json.c_str()));
+    }
+
+    virtual void finished(CompletionDetails const &details) override {
+        int status;
+        switch (details.status()) {
+        case CompletionDetails::OK:
+            status = 0;
+            break;
+        case CompletionDetails::Cancelled:
+            status = 1;
+            break;
+        default:
+            status = 2;
+            break;
+        }
+        subSearchFinished(handle, status,
+                          const_cast<char*>(details.message().c_str()));
+    }
+
+private:
+    uintptr_t handle;
+};
+
+}
+
+_SubSearch *scope_base_subsearch(_ScopeBase *scope, const StrData scope_id, _CannedQuery *query, _SearchMetadata *metadata, uintptr_t handle, char **error) {
+    try {
+        ScopeBase *s = reinterpret_cast<ScopeBase*>(scope);
+        CannedQuery *q = reinterpret_cast<CannedQuery*>(query);
+        auto proxy = s->registry()->get_metadata(from_gostring(scope_id)).proxy();
+        SearchListenerBase::SPtr listener(new SubSearchListener(handle));
+        auto ctrl = proxy->search(q->query_string(), q->department_id(),
+                                  q->filter_state(),
+                                  *reinterpret_cast<SearchMetadata*>(metadata),
+                                  listener);
+        return reinterpret_cast<_SubSearch*>(new QueryCtrlProxy(ctrl));
+    } catch (const std::exception &e) {
+        *error = strdup(e.what());
+        return nullptr;
+    }
+}
+
+void sub_search_cancel(_SubSearch *search) {
+    try {
+        (*reinterpret_cast<QueryCtrlProxy*>(search))->cancel();
+    } catch (...) {
+        // The child scope may already have gone away.
+    }
+}
+
+void destroy_sub_search(_SubSearch *search) {
+    delete reinterpret_cast<QueryCtrlProxy*>(search);
+}
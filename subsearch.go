@@ -0,0 +1,296 @@
+package scopes
+
+// #include <stdlib.h>
+// #include "shim.h"
+import "C"
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// ErrSubSearchCancelled is returned by SubSearch.Err when the child
+// query was cancelled before it completed.
+var ErrSubSearchCancelled = errors.New("subsearch cancelled")
+
+// SubSearchEvent is a reply sent by a child scope to a SubSearch.
+// Exactly one of Result, Category, Department or Filters is set.
+type SubSearchEvent struct {
+	// Result is set when the child pushes a search result.
+	Result *CategorisedResult
+
+	// Category is set when the child registers a category.
+	Category *Category
+
+	// Department is set when the child registers its departments.
+	Department *Department
+
+	// Filters and FilterState are set when the child pushes its
+	// filters.  The filters may be passed on to the client with
+	// SearchReply.PushFilters.
+	Filters     []Filter
+	FilterState FilterState
+}
+
+// childFilter is a filter received from a child scope, kept in its
+// serialised form.
+type childFilter map[string]interface{}
+
+func (f childFilter) serializeFilter() map[string]interface{} {
+	return f
+}
+
+// SubSearch is a search query sent to another scope, typically a
+// child of an aggregator scope.
+type SubSearch struct {
+	s      *C._SubSearch
+	handle uintptr
+	events chan *SubSearchEvent
+
+	lock      sync.Mutex
+	queue     []*SubSearchEvent
+	notify    chan struct{}
+	finished  bool
+	cancelled chan struct{}
+	err       error
+	done      chan struct{}
+}
+
+var (
+	subSearches     = make(map[uintptr]*SubSearch)
+	lastSubSearch   uintptr
+	subSearchesLock sync.Mutex
+)
+
+func newSubSearch() *SubSearch {
+	search := &SubSearch{
+		events:    make(chan *SubSearchEvent),
+		notify:    make(chan struct{}, 1),
+		cancelled: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	subSearchesLock.Lock()
+	lastSubSearch++
+	search.handle = lastSubSearch
+	subSearches[search.handle] = search
+	subSearchesLock.Unlock()
+	go search.deliver()
+	return search
+}
+
+func lookupSubSearch(handle C.uintptr_t) *SubSearch {
+	subSearchesLock.Lock()
+	defer subSearchesLock.Unlock()
+	return subSearches[uintptr(handle)]
+}
+
+func forgetSubSearch(search *SubSearch) {
+	subSearchesLock.Lock()
+	defer subSearchesLock.Unlock()
+	delete(subSearches, search.handle)
+}
+
+// SubSearch sends a search query to the scope with the given ID,
+// usually one of the scopes returned by ChildScopes.  The query
+// string, department and filter state are taken from query.
+//
+// The child's replies are delivered on the channel returned by
+// Events.  If the cancelled channel is closed, typically because the
+// parent query was cancelled, the subsearch is cancelled too.
+func (b *ScopeBase) SubSearch(scopeId string, query *CannedQuery, metadata *SearchMetadata, cancelled <-chan bool) (*SubSearch, error) {
+	search := newSubSearch()
+	var errorString *C.char
+	s := C.scope_base_subsearch(b.b, strData(scopeId), query.q, (*C._SearchMetadata)(metadata.m), C.uintptr_t(search.handle), &errorString)
+	if err := checkError(errorString); err != nil {
+		search.finish(err)
+		return nil, err
+	}
+	search.lock.Lock()
+	if search.finished {
+		// The child completed before we got the query
+		// controller back.
+		C.destroy_sub_search(s)
+	} else {
+		search.s = s
+	}
+	search.lock.Unlock()
+
+	go func() {
+		select {
+		case <-cancelled:
+			search.Cancel()
+		case <-search.done:
+		}
+	}()
+	return search, nil
+}
+
+// Events returns the channel the child's replies are delivered on.
+// It is closed once the child has finished, after which Err reports
+// the outcome of the query.
+func (search *SubSearch) Events() <-chan *SubSearchEvent {
+	return search.events
+}
+
+// Done returns a channel that is closed once the child has finished
+// and every event has been delivered or discarded.
+func (search *SubSearch) Done() <-chan struct{} {
+	return search.done
+}
+
+// Err returns the error reported by the child, or
+// ErrSubSearchCancelled if the query was cancelled.  It returns nil
+// while the query is running and if it completed successfully.
+func (search *SubSearch) Err() error {
+	search.lock.Lock()
+	defer search.lock.Unlock()
+	return search.err
+}
+
+// Cancel cancels the query.  Events not yet received are discarded,
+// and the events channel is closed once the child acknowledges the
+// cancellation.
+func (search *SubSearch) Cancel() {
+	search.lock.Lock()
+	defer search.lock.Unlock()
+	select {
+	case <-search.cancelled:
+		return
+	default:
+	}
+	close(search.cancelled)
+	if search.s != nil {
+		C.sub_search_cancel(search.s)
+	}
+}
+
+func (search *SubSearch) push(event *SubSearchEvent) {
+	search.lock.Lock()
+	search.queue = append(search.queue, event)
+	search.lock.Unlock()
+	search.wakeup()
+}
+
+func (search *SubSearch) finish(err error) {
+	search.lock.Lock()
+	if !search.finished {
+		search.finished = true
+		search.err = err
+	}
+	search.lock.Unlock()
+	forgetSubSearch(search)
+	search.wakeup()
+}
+
+func (search *SubSearch) wakeup() {
+	select {
+	case search.notify <- struct{}{}:
+	default:
+	}
+}
+
+// deliver passes the queued events to the events channel, so that the
+// child scope is never blocked by a slow or absent receiver.
+func (search *SubSearch) deliver() {
+	defer close(search.done)
+	defer close(search.events)
+	for {
+		search.lock.Lock()
+		if len(search.queue) == 0 {
+			if search.finished {
+				if search.s != nil {
+					C.destroy_sub_search(search.s)
+					search.s = nil
+				}
+				search.lock.Unlock()
+				return
+			}
+			search.lock.Unlock()
+			<-search.notify
+			continue
+		}
+		event := search.queue[0]
+		search.queue[0] = nil
+		search.queue = search.queue[1:]
+		search.lock.Unlock()
+
+		select {
+		case search.events <- event:
+		case <-search.cancelled:
+			search.lock.Lock()
+			search.queue = nil
+			search.lock.Unlock()
+		}
+	}
+}
+
+//export subSearchPushResult
+func subSearchPushResult(handle C.uintptr_t, result *C._Result) {
+	res := new(CategorisedResult)
+	runtime.SetFinalizer(res, finalizeCategorisedResult)
+	res.result = result
+	if search := lookupSubSearch(handle); search != nil {
+		search.push(&SubSearchEvent{Result: res})
+	}
+}
+
+//export subSearchPushCategory
+func subSearchPushCategory(handle C.uintptr_t, category *C.uintptr_t) {
+	if search := lookupSubSearch(handle); search != nil {
+		cat := new(Category)
+		runtime.SetFinalizer(cat, finalizeCategory)
+		C.init_category_ptr(&cat.c[0], category)
+		search.push(&SubSearchEvent{Category: cat})
+	}
+}
+
+//export subSearchPushDepartment
+func subSearchPushDepartment(handle C.uintptr_t, department *C.uintptr_t) {
+	if search := lookupSubSearch(handle); search != nil {
+		dept := makeDepartment()
+		C.init_department_ptr(&dept.d[0], department)
+		search.push(&SubSearchEvent{Department: dept})
+	}
+}
+
+//export subSearchPushFilters
+func subSearchPushFilters(handle C.uintptr_t, filtersJson, stateJson *C.char) {
+	search := lookupSubSearch(handle)
+	if search == nil {
+		return
+	}
+	var filterData []map[string]interface{}
+	if err := json.Unmarshal([]byte(C.GoString(filtersJson)), &filterData); err != nil {
+		logf("Could not decode filters of subsearch: %v", err)
+		return
+	}
+	var state FilterState
+	if err := json.Unmarshal([]byte(C.GoString(stateJson)), &state); err != nil {
+		logf("Could not decode filter state of subsearch: %v", err)
+		return
+	}
+	filters := make([]Filter, len(filterData))
+	for i, f := range filterData {
+		filters[i] = childFilter(f)
+	}
+	search.push(&SubSearchEvent{Filters: filters, FilterState: state})
+}
+
+//export subSearchFinished
+func subSearchFinished(handle C.uintptr_t, status C.int, message *C.char) {
+	search := lookupSubSearch(handle)
+	if search == nil {
+		return
+	}
+	var err error
+	switch status {
+	case 0:
+		// success
+	case 1:
+		err = ErrSubSearchCancelled
+	default:
+		err = errors.New(C.GoString(message))
+	}
+	search.finish(err)
+}
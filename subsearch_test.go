@@ -0,0 +1,50 @@
+package scopes_test
+
+import (
+	"errors"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestSubSearchEvents(c *C) {
+	search := scopes.NewTestingSubSearch()
+	search.TestingPush(&scopes.SubSearchEvent{FilterState: scopes.FilterState{"n": 1.0}})
+	search.TestingPush(&scopes.SubSearchEvent{FilterState: scopes.FilterState{"n": 2.0}})
+	search.TestingFinish(nil)
+
+	var received []scopes.FilterState
+	for event := range search.Events() {
+		received = append(received, event.FilterState)
+	}
+	c.Check(received, DeepEquals, []scopes.FilterState{{"n": 1.0}, {"n": 2.0}})
+	c.Check(search.Err(), IsNil)
+	<-search.Done()
+}
+
+func (s *S) TestSubSearchError(c *C) {
+	search := scopes.NewTestingSubSearch()
+	c.Check(search.Err(), IsNil)
+	search.TestingFinish(errors.New("child failed"))
+	_, ok := <-search.Events()
+	c.Check(ok, Equals, false)
+	c.Check(search.Err(), ErrorMatches, "child failed")
+}
+
+func (s *S) TestSubSearchCancelDiscardsEvents(c *C) {
+	search := scopes.NewTestingSubSearch()
+	search.TestingPush(&scopes.SubSearchEvent{})
+	search.TestingPush(&scopes.SubSearchEvent{})
+	search.Cancel()
+	search.Cancel()
+	search.TestingFinish(scopes.ErrSubSearchCancelled)
+
+	// The undelivered events are dropped without a receiver.
+	select {
+	case <-search.Done():
+	case <-time.After(5 * time.Second):
+		c.Fatal("subsearch did not complete")
+	}
+	c.Check(search.Err(), Equals, scopes.ErrSubSearchCancelled)
+}
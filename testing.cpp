@@ -7,6 +7,7 @@ extern "C" {
 #include "_cgo_export.h"
 }
 #include "helpers.h"
+#include "smartptr_helper.h"
 
 using namespace unity::scopes;
 using namespace gounityscopes::internal;
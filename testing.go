@@ -1,7 +1,11 @@
 package scopes
 
+// #include <stdlib.h>
 // #include "shim.h"
 import "C"
+import (
+	"unsafe"
+)
 
 // These functions are used by tests.  They are not part of a
 // *_test.go file because they make use of cgo.
@@ -9,3 +13,20 @@ import "C"
 func newTestingResult() *Result {
 	return makeResult(C.new_testing_result())
 }
+
+// testingCallScopeStart and testingCallScopeStop call the lifecycle
+// hooks the way the C++ scope adapter does, returning the error
+// string it would receive.
+func testingCallScopeStart(scope ScopeBaseSetter, scopeId string) error {
+	id := C.CString(scopeId)
+	defer C.free(unsafe.Pointer(id))
+	var errorString *C.char
+	callScopeStart(scope, id, &errorString)
+	return checkError(errorString)
+}
+
+func testingCallScopeStop(scope ScopeBaseSetter) error {
+	var errorString *C.char
+	callScopeStop(scope, &errorString)
+	return checkError(errorString)
+}
@@ -158,6 +158,7 @@ func (s *MyScope) AddQueryResults(reply *scopes.SearchReply, query string) error
 		return err
 	}
 
+	result = result.Clone()
 	result.SetURI("http://localhost2/" + query)
 	result.SetDndURI("http://localhost_dnduri2" + query)
 	result.SetTitle("TEST2")
@@ -0,0 +1,196 @@
+package scopes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQueryTimeout is reported to the client when a query does not
+// complete within its configured timeout.
+var ErrQueryTimeout = errors.New("scope query timed out")
+
+// QueryTimeouts holds the maximum time each kind of query may take.
+// A zero duration means the query is not bounded.
+type QueryTimeouts struct {
+	Search        time.Duration
+	Preview       time.Duration
+	Activate      time.Duration
+	PerformAction time.Duration
+}
+
+var (
+	// configTimeouts holds the timeouts read from the scope
+	// configuration file, and queryTimeouts those set with
+	// SetQueryTimeouts.
+	configTimeouts    QueryTimeouts
+	queryTimeouts     QueryTimeouts
+	queryTimeoutsLock sync.Mutex
+)
+
+// SetQueryTimeouts sets the maximum time each kind of query may take.
+// Once a timeout expires, the query is cancelled and ErrQueryTimeout
+// is reported to the client.  Contexts passed to ContextScope methods
+// carry the corresponding deadline.
+//
+// Activate and PerformAction calls that time out are abandoned: the
+// context passed to ContextActivator and ContextPerformActioner
+// methods is cancelled, but Activator and PerformActioner
+// implementations keep running until they return.
+//
+// Non-zero durations override the defaults read from the GoScope group
+// of the scope configuration file, and a negative duration removes the
+// default timeout.
+func SetQueryTimeouts(timeouts QueryTimeouts) {
+	queryTimeoutsLock.Lock()
+	defer queryTimeoutsLock.Unlock()
+	queryTimeouts = timeouts
+}
+
+func setConfigTimeouts(timeouts QueryTimeouts) {
+	queryTimeoutsLock.Lock()
+	defer queryTimeoutsLock.Unlock()
+	configTimeouts = timeouts
+}
+
+func currentQueryTimeouts() QueryTimeouts {
+	queryTimeoutsLock.Lock()
+	defer queryTimeoutsLock.Unlock()
+	return QueryTimeouts{
+		Search:        mergeTimeout(configTimeouts.Search, queryTimeouts.Search),
+		Preview:       mergeTimeout(configTimeouts.Preview, queryTimeouts.Preview),
+		Activate:      mergeTimeout(configTimeouts.Activate, queryTimeouts.Activate),
+		PerformAction: mergeTimeout(configTimeouts.PerformAction, queryTimeouts.PerformAction),
+	}
+}
+
+// timeoutConfigGroup is the group of the scope configuration file
+// holding the query timeouts.
+const timeoutConfigGroup = "GoScope"
+
+// readQueryTimeouts reads the default query timeouts from the scope
+// configuration file.  They are given as Go durations in the GoScope
+// group:
+//
+//	[GoScope]
+//	SearchTimeout = 10s
+//	PreviewTimeout = 5s
+//	ActivateTimeout = 2s
+//	PerformActionTimeout = 2s
+func readQueryTimeouts(filename string) (QueryTimeouts, error) {
+	var timeouts QueryTimeouts
+	f, err := os.Open(filename)
+	if err != nil {
+		return timeouts, err
+	}
+	defer f.Close()
+
+	fields := map[string]*time.Duration{
+		"SearchTimeout":        &timeouts.Search,
+		"PreviewTimeout":       &timeouts.Preview,
+		"ActivateTimeout":      &timeouts.Activate,
+		"PerformActionTimeout": &timeouts.PerformAction,
+	}
+	group := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			group = line[1 : len(line)-1]
+			continue
+		}
+		if group != timeoutConfigGroup {
+			continue
+		}
+		pos := strings.Index(line, "=")
+		if pos < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:pos])
+		field, ok := fields[key]
+		if !ok {
+			continue
+		}
+		value := strings.TrimSpace(line[pos+1:])
+		if *field, err = time.ParseDuration(value); err != nil {
+			return timeouts, fmt.Errorf("Invalid %s in %s: %v", key, filename, err)
+		}
+	}
+	return timeouts, scanner.Err()
+}
+
+func mergeTimeout(configured, override time.Duration) time.Duration {
+	switch {
+	case override < 0:
+		return 0
+	case override > 0:
+		return override
+	default:
+		return configured
+	}
+}
+
+// queryReply is the part of SearchReply and PreviewReply used to
+// complete a query.  Both methods take the reply's lock and mark it
+// finished, so the query timer may complete the reply while the scope
+// is still pushing to it.
+type queryReply interface {
+	Finished()
+	Error(err error)
+}
+
+// newQueryTimer arranges for a query to be cancelled and the timeout
+// reported on its reply once the timeout expires.  It returns nil if
+// the query is not bounded.
+func newQueryTimer(timeout time.Duration, cancel chan bool, reply queryReply) *time.Timer {
+	if timeout <= 0 {
+		return nil
+	}
+	return time.AfterFunc(timeout, func() {
+		sendCancelChannel(cancel)
+		reply.Error(ErrQueryTimeout)
+	})
+}
+
+// stopQueryTimer stops a timer created by newQueryTimer, and returns
+// false if the timeout has already been reported.
+func stopQueryTimer(timer *time.Timer) bool {
+	if timer == nil {
+		return true
+	}
+	return timer.Stop()
+}
+
+// callWithTimeout calls f, returning ErrQueryTimeout if it does not
+// return within the timeout.  The context passed to f is cancelled
+// when the timeout expires, but f keeps running in the background and
+// the caller must not touch any data it writes.  Implementations of
+// the context-free Activator and PerformActioner interfaces are thus
+// abandoned but not stopped.
+func callWithTimeout(timeout time.Duration, f func(ctx context.Context) error) error {
+	ctx, cancel := newQueryContext(nil, timeout)
+	defer cancel()
+	if timeout <= 0 {
+		return f(ctx)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrQueryTimeout
+	}
+}
@@ -0,0 +1,124 @@
+package scopes_test
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+const timeoutScopeConfig = `[ScopeConfig]
+DisplayName = Test scope
+SearchTimeout = 1h
+
+[GoScope]
+# Timeouts for the queries
+SearchTimeout = 10s
+PreviewTimeout = 500ms
+PerformActionTimeout = 1m
+`
+
+func (s *S) TestReadQueryTimeouts(c *C) {
+	filename := filepath.Join(c.MkDir(), "scope.ini")
+	c.Assert(ioutil.WriteFile(filename, []byte(timeoutScopeConfig), 0644), IsNil)
+
+	timeouts, err := scopes.ReadQueryTimeouts(filename)
+	c.Assert(err, IsNil)
+	c.Check(timeouts, Equals, scopes.QueryTimeouts{
+		Search:        10 * time.Second,
+		Preview:       500 * time.Millisecond,
+		PerformAction: time.Minute,
+	})
+}
+
+func (s *S) TestReadQueryTimeoutsInvalid(c *C) {
+	filename := filepath.Join(c.MkDir(), "scope.ini")
+	c.Assert(ioutil.WriteFile(filename, []byte("[GoScope]\nSearchTimeout = soon\n"), 0644), IsNil)
+
+	_, err := scopes.ReadQueryTimeouts(filename)
+	c.Check(err, ErrorMatches, `Invalid SearchTimeout in .*scope.ini: .*`)
+}
+
+func (s *S) TestQueryTimeoutsOverride(c *C) {
+	defer scopes.SetConfigTimeouts(scopes.QueryTimeouts{})
+	defer scopes.SetQueryTimeouts(scopes.QueryTimeouts{})
+
+	scopes.SetConfigTimeouts(scopes.QueryTimeouts{
+		Search:  10 * time.Second,
+		Preview: 5 * time.Second,
+	})
+	scopes.SetQueryTimeouts(scopes.QueryTimeouts{
+		Search:   time.Second,
+		Preview:  -1,
+		Activate: 2 * time.Second,
+	})
+	c.Check(scopes.CurrentQueryTimeouts(), Equals, scopes.QueryTimeouts{
+		Search:   time.Second,
+		Activate: 2 * time.Second,
+	})
+}
+
+func (s *S) TestCallWithTimeout(c *C) {
+	noop := func(ctx context.Context) error { return nil }
+	c.Check(scopes.CallWithTimeout(0, noop), IsNil)
+	c.Check(scopes.CallWithTimeout(time.Second, noop), IsNil)
+
+	block := make(chan bool)
+	defer close(block)
+	contexts := make(chan context.Context, 1)
+	err := scopes.CallWithTimeout(10*time.Millisecond, func(ctx context.Context) error {
+		contexts <- ctx
+		<-block
+		return nil
+	})
+	c.Check(err, Equals, scopes.ErrQueryTimeout)
+
+	// The abandoned call can notice the timeout through its
+	// context.
+	ctx := <-contexts
+	select {
+	case <-ctx.Done():
+	default:
+		c.Error("context was not cancelled")
+	}
+}
+
+// recordingReply records how a query was completed.
+type recordingReply struct {
+	finished chan bool
+	errors   chan error
+}
+
+func newRecordingReply() *recordingReply {
+	return &recordingReply{make(chan bool, 1), make(chan error, 1)}
+}
+
+func (r *recordingReply) Finished() {
+	r.finished <- true
+}
+
+func (r *recordingReply) Error(err error) {
+	r.errors <- err
+}
+
+func (s *S) TestQueryTimer(c *C) {
+	c.Check(scopes.NewQueryTimer(0, nil, newRecordingReply()), IsNil)
+	c.Check(scopes.StopQueryTimer(nil), Equals, true)
+
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	reply := newRecordingReply()
+	timer := scopes.NewQueryTimer(10*time.Millisecond, ch, reply)
+	select {
+	case err := <-reply.errors:
+		c.Check(err, Equals, scopes.ErrQueryTimeout)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout was not reported")
+	}
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+	c.Check(scopes.StopQueryTimer(timer), Equals, false)
+	c.Check(len(reply.finished), Equals, 0)
+}
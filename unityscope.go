@@ -8,12 +8,15 @@ package scopes
 */
 import "C"
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
 	"path"
 	"strings"
 	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -35,12 +38,22 @@ func finalizeCategory(cat *Category) {
 }
 
 // Scope defines the interface that scope implementations must implement
+//
+// The cancelled channel passed to Search and Preview is closed when the
+// client cancels the query, so it may be watched by any number of
+// goroutines.
 type Scope interface {
 	SetScopeBase(base *ScopeBase)
 	Search(query *CannedQuery, metadata *SearchMetadata, reply *SearchReply, cancelled <-chan bool) error
 	Preview(result *Result, metadata *ActionMetadata, reply *PreviewReply, cancelled <-chan bool) error
 }
 
+// ScopeBaseSetter is the method shared by Scope and ContextScope.
+// RunWithOptions accepts any value implementing either interface.
+type ScopeBaseSetter interface {
+	SetScopeBase(base *ScopeBase)
+}
+
 type AggregatedScope interface {
 	Scope
 	FindChildScopes() []*ChildScope
@@ -60,58 +73,215 @@ type PerformActioner interface {
 	PerformAction(result *Result, metadata *ActionMetadata, widgetId, actionId string) (*ActivationResponse, error)
 }
 
+// Starter is an interface that should be implemented by scopes that
+// need to perform initialisation when the scope runtime starts them,
+// such as opening database handles or warming caches.
+//
+// Start is called after SetScopeBase with the ID of the scope.  If an
+// error is returned, the scope fails to start.
+type Starter interface {
+	Start(scopeId string) error
+}
+
+// Stopper is an interface that should be implemented by scopes that
+// need to release resources or flush state when the scope runtime
+// shuts them down.
+//
+// Stop is called before the scope base is cleared with
+// SetScopeBase(nil).
+type Stopper interface {
+	Stop() error
+}
+
+//export callScopeStart
+func callScopeStart(scope ScopeBaseSetter, scopeId *C.char, errorPtr **C.char) {
+	if err := startScope(scope, C.GoString(scopeId)); err != nil {
+		*errorPtr = C.CString(err.Error())
+	}
+}
+
+//export callScopeStop
+func callScopeStop(scope ScopeBaseSetter, errorPtr **C.char) {
+	cancelAllQueries()
+	if err := stopScope(scope); err != nil {
+		*errorPtr = C.CString(err.Error())
+	}
+}
+
+// startScope calls the Start method of scopes implementing Starter.
+func startScope(scope ScopeBaseSetter, scopeId string) error {
+	switch s := scope.(type) {
+	case Starter:
+		return callProtected(func() error {
+			return s.Start(scopeId)
+		})
+	default:
+		return nil
+	}
+}
+
+// stopScope calls the Stop method of scopes implementing Stopper.
+func stopScope(scope ScopeBaseSetter) error {
+	switch s := scope.(type) {
+	case Stopper:
+		return callProtected(s.Stop)
+	default:
+		return nil
+	}
+}
+
 //export callScopeSearch
-func callScopeSearch(scope Scope, queryPtr, metadataPtr unsafe.Pointer, replyData *C.uintptr_t, cancel <-chan bool) {
+func callScopeSearch(scope ScopeBaseSetter, queryPtr, metadataPtr unsafe.Pointer, replyData *C.uintptr_t, cancel chan bool) {
 	query := makeCannedQuery((*C._CannedQuery)(queryPtr))
 	metadata := makeSearchMetadata((*C._SearchMetadata)(metadataPtr))
-	reply := makeSearchReply(replyData)
-
+	reply := makeSearchReply(replyData, cancel, newQueryLogger(
+		LogField{LogFieldQuery, query.QueryString()},
+		LogField{LogFieldDepartmentId, query.DepartmentID()},
+		LogField{LogFieldFormFactor, metadata.FormFactor()},
+	), metadata.Cardinality())
+
+	timeout := currentQueryTimeouts().Search
+	if !startQuery(cancel) {
+		reply.Error(ErrScopeStopping)
+		return
+	}
 	go func() {
-		err := scope.Search(query, metadata, reply, cancel)
-		if err != nil {
-			reply.Error(err)
+		defer finishQuery(cancel)
+		start := time.Now()
+		queryStarted(&metrics.Search)
+		timer := newQueryTimer(timeout, cancel, reply)
+		if err := searchLimiter.acquire(cancel); err != nil {
+			timedOut := !stopQueryTimer(timer)
+			queryFinished(&metrics.Search, time.Since(start), outcomeOf(err, cancel, timedOut))
+			if !timedOut {
+				reply.Error(err)
+			}
 			return
 		}
-		reply.Finished()
+		defer searchLimiter.release()
+		err := callProtected(func() error {
+			switch s := scope.(type) {
+			case ContextScope:
+				ctx, cancelCtx := newQueryContext(cancel, timeout)
+				defer cancelCtx()
+				ctx = context.WithValue(ctx, cannedQueryKey, query)
+				ctx = context.WithValue(ctx, searchMetadataKey, metadata)
+				return s.SearchContext(ctx, query, metadata, reply)
+			default:
+				return scope.(Scope).Search(query, metadata, reply, cancel)
+			}
+		})
+		timedOut := !stopQueryTimer(timer)
+		queryFinished(&metrics.Search, time.Since(start), outcomeOf(err, cancel, timedOut))
+		if timedOut {
+			// The timeout has already been reported.
+			return
+		}
+		completeReply(reply, err)
 	}()
 }
 
 //export callScopePreview
-func callScopePreview(scope Scope, resultPtr, metadataPtr unsafe.Pointer, replyData *C.uintptr_t, cancel <-chan bool) {
+func callScopePreview(scope ScopeBaseSetter, resultPtr, metadataPtr unsafe.Pointer, replyData *C.uintptr_t, cancel chan bool) {
 	result := makeResult((*C._Result)(resultPtr))
 	metadata := makeActionMetadata((*C._ActionMetadata)(metadataPtr))
-	reply := makePreviewReply(replyData)
-
+	reply := makePreviewReply(replyData, cancel, newQueryLogger(
+		LogField{LogFieldFormFactor, metadata.FormFactor()},
+		LogField{LogFieldResultURI, result.URI()},
+	))
+
+	timeout := currentQueryTimeouts().Preview
+	if !startQuery(cancel) {
+		reply.Error(ErrScopeStopping)
+		return
+	}
 	go func() {
-		err := scope.Preview(result, metadata, reply, cancel)
-		if err != nil {
-			reply.Error(err)
+		defer finishQuery(cancel)
+		start := time.Now()
+		queryStarted(&metrics.Preview)
+		timer := newQueryTimer(timeout, cancel, reply)
+		if err := previewLimiter.acquire(cancel); err != nil {
+			timedOut := !stopQueryTimer(timer)
+			queryFinished(&metrics.Preview, time.Since(start), outcomeOf(err, cancel, timedOut))
+			if !timedOut {
+				reply.Error(err)
+			}
 			return
 		}
-		reply.Finished()
+		defer previewLimiter.release()
+		err := callProtected(func() error {
+			switch s := scope.(type) {
+			case ContextScope:
+				ctx, cancelCtx := newQueryContext(cancel, timeout)
+				defer cancelCtx()
+				ctx = context.WithValue(ctx, actionMetadataKey, metadata)
+				return s.PreviewContext(ctx, result, metadata, reply)
+			default:
+				return scope.(Scope).Preview(result, metadata, reply, cancel)
+			}
+		})
+		timedOut := !stopQueryTimer(timer)
+		queryFinished(&metrics.Preview, time.Since(start), outcomeOf(err, cancel, timedOut))
+		if timedOut {
+			// The timeout has already been reported.
+			return
+		}
+		completeReply(reply, err)
 	}()
 }
 
+// completeReply finishes the reply of a query that returned err.
+// The errors returned by Push when the scope should stop pushing
+// results do not make the query fail.
+func completeReply(reply queryReply, err error) {
+	if err != nil && !pushStopped(err) {
+		reply.Error(err)
+		return
+	}
+	reply.Finished()
+}
+
 //export callScopeActivate
-func callScopeActivate(scope Scope, resultPtr, metadataPtr, responsePtr unsafe.Pointer, errorPtr **C.char) {
+func callScopeActivate(scope ScopeBaseSetter, resultPtr, metadataPtr, responsePtr unsafe.Pointer, errorPtr **C.char) {
+	var activate func(ctx context.Context, result *Result, metadata *ActionMetadata) (*ActivationResponse, error)
 	switch s := scope.(type) {
-	case Activator:
-		result := makeResult((*C._Result)(resultPtr))
-		metadata := makeActionMetadata((*C._ActionMetadata)(metadataPtr))
-		response, err := s.Activate(result, metadata)
-		if err == nil {
-			err = response.update((*C._ActivationResponse)(responsePtr))
+	case ContextActivator:
+		activate = func(ctx context.Context, result *Result, metadata *ActionMetadata) (*ActivationResponse, error) {
+			ctx = context.WithValue(ctx, actionMetadataKey, metadata)
+			return s.ActivateContext(ctx, result, metadata)
 		}
-		if err != nil {
-			*errorPtr = C.CString(err.Error())
+	case Activator:
+		activate = func(ctx context.Context, result *Result, metadata *ActionMetadata) (*ActivationResponse, error) {
+			return s.Activate(result, metadata)
 		}
 	default:
 		// nothing
+		return
+	}
+	result := makeResult((*C._Result)(resultPtr))
+	metadata := makeActionMetadata((*C._ActionMetadata)(metadataPtr))
+	var response *ActivationResponse
+	err := callWithTimeout(currentQueryTimeouts().Activate, func(ctx context.Context) error {
+		return callProtected(func() (err error) {
+			response, err = activate(ctx, result, metadata)
+			return err
+		})
+	})
+	if err == nil {
+		// The response is only written once the call has returned
+		// in time, as the C++ response is gone once it is
+		// abandoned.
+		err = callProtected(func() error {
+			return response.update((*C._ActivationResponse)(responsePtr))
+		})
+	}
+	if err != nil {
+		*errorPtr = C.CString(err.Error())
 	}
 }
 
 //export callFindChildScopes
-func callFindChildScopes(scope Scope, childScopeList unsafe.Pointer) {
+func callFindChildScopes(scope ScopeBaseSetter, childScopeList unsafe.Pointer) {
 	switch s := scope.(type) {
 	case AggregatedScope:
 		go_child_scopes := s.FindChildScopes()
@@ -126,28 +296,42 @@ func callFindChildScopes(scope Scope, childScopeList unsafe.Pointer) {
 }
 
 //export callScopePerformAction
-func callScopePerformAction(scope Scope, resultPtr, metadataPtr unsafe.Pointer, widgetId, actionId *C.char, responsePtr unsafe.Pointer, errorPtr **C.char) {
+func callScopePerformAction(scope ScopeBaseSetter, resultPtr, metadataPtr unsafe.Pointer, widgetId, actionId *C.char, responsePtr unsafe.Pointer, errorPtr **C.char) {
+	var performAction func(ctx context.Context, result *Result, metadata *ActionMetadata, widgetId, actionId string) (*ActivationResponse, error)
 	switch s := scope.(type) {
-	case PerformActioner:
-		result := makeResult((*C._Result)(resultPtr))
-		metadata := makeActionMetadata((*C._ActionMetadata)(metadataPtr))
-		response, err := s.PerformAction(result, metadata, C.GoString(widgetId), C.GoString(actionId))
-		if err == nil {
-			err = response.update((*C._ActivationResponse)(responsePtr))
+	case ContextPerformActioner:
+		performAction = func(ctx context.Context, result *Result, metadata *ActionMetadata, widgetId, actionId string) (*ActivationResponse, error) {
+			ctx = context.WithValue(ctx, actionMetadataKey, metadata)
+			return s.PerformActionContext(ctx, result, metadata, widgetId, actionId)
 		}
-		if err != nil {
-			*errorPtr = C.CString(err.Error())
+	case PerformActioner:
+		performAction = func(ctx context.Context, result *Result, metadata *ActionMetadata, widgetId, actionId string) (*ActivationResponse, error) {
+			return s.PerformAction(result, metadata, widgetId, actionId)
 		}
 	default:
 		// nothing
+		return
+	}
+	result := makeResult((*C._Result)(resultPtr))
+	metadata := makeActionMetadata((*C._ActionMetadata)(metadataPtr))
+	widget, action := C.GoString(widgetId), C.GoString(actionId)
+	var response *ActivationResponse
+	err := callWithTimeout(currentQueryTimeouts().PerformAction, func(ctx context.Context) error {
+		return callProtected(func() (err error) {
+			response, err = performAction(ctx, result, metadata, widget, action)
+			return err
+		})
+	})
+	if err == nil {
+		err = callProtected(func() error {
+			return response.update((*C._ActivationResponse)(responsePtr))
+		})
+	}
+	if err != nil {
+		*errorPtr = C.CString(err.Error())
 	}
 }
 
-var (
-	runtimeConfig = flag.String("runtime", "", "The runtime configuration file for the Unity Scopes library")
-	scopeConfig   = flag.String("scope", "", "The scope configuration file for the Unity Scopes library")
-)
-
 // ScopeBase exposes information about the scope including settings
 // and various directories available for use.
 type ScopeBase struct {
@@ -155,11 +339,14 @@ type ScopeBase struct {
 }
 
 //export setScopeBase
-func setScopeBase(scope Scope, b unsafe.Pointer) {
+func setScopeBase(scope ScopeBaseSetter, b unsafe.Pointer) {
 	if b == nil {
+		stopMetricsDump()
 		scope.SetScopeBase(nil)
 	} else {
-		scope.SetScopeBase(&ScopeBase{b})
+		base := &ScopeBase{b}
+		startMetricsDump(base.CacheDirectory())
+		scope.SetScopeBase(base)
 	}
 }
 
@@ -235,51 +422,352 @@ func (b *ScopeBase) Settings(value interface{}) error {
 	return json.Unmarshal(C.GoBytes(data, length), value)
 }
 
+var (
+	runtimeConfig = flag.String("runtime", "", "The runtime configuration file for the Unity Scopes library")
+	scopeConfig   = flag.String("scope", "", "The scope configuration file for the Unity Scopes library")
+)
+
 /*
 Run will initialise the scope runtime and make a scope availble.  It
 is intended to be called from the program's main function, and will
-run until the program exits.
+run until the scope is stopped by the runtime or by Shutdown.
+
+The runtime and scope configuration files are taken from the
+--runtime and --scope command line flags.  The command line is parsed
+with the flag package if the program has not done so already.
+Programs that manage their own command line can use RunWithOptions
+instead.
 */
 func Run(scope Scope) error {
+	return runFromFlags(scope)
+}
+
+// RunContext is like Run, for scopes implementing ContextScope rather
+// than Scope.
+func RunContext(scope ContextScope) error {
+	return runFromFlags(scope)
+}
+
+// runFromFlags runs a scope configured by the --runtime and --scope
+// command line flags.
+func runFromFlags(scope ScopeBaseSetter) error {
 	if !flag.Parsed() {
 		flag.Parse()
 	}
 	if *scopeConfig == "" {
 		return errors.New("Scope configuration file not set on command line")
 	}
-	base := path.Base(*scopeConfig)
-	if !strings.HasSuffix(base, ".ini") {
-		return errors.New("Scope configuration file does not end in '.ini'")
+	return RunWithOptions(scope, Options{
+		RuntimeConfig: *runtimeConfig,
+		ScopeConfig:   *scopeConfig,
+	})
+}
+
+// Options holds the configuration used to run a scope with
+// RunWithOptions.
+type Options struct {
+	// RuntimeConfig is the path to the runtime configuration file
+	// for the Unity Scopes library.  If empty, the library's
+	// default configuration is used.
+	RuntimeConfig string
+
+	// ScopeConfig is the path to the scope's .ini configuration
+	// file.  It is required.
+	ScopeConfig string
+
+	// ScopeId overrides the scope ID.  If empty, it is derived
+	// from the name of ScopeConfig, which must then end in ".ini".
+	ScopeId string
+
+	// LogSinks replace the destinations of the log entries of the
+	// scope and of the library, as with SetLogSinks.  If nil, the
+	// current sinks are kept.
+	LogSinks []LogSink
+
+	// ShutdownGracePeriod is how long to wait for in-flight
+	// queries to return once the scope has stopped.  If zero, the
+	// period set with SetShutdownGracePeriod is used.
+	ShutdownGracePeriod time.Duration
+
+	// MetricsInterval is how often the scope's metrics are written
+	// to metrics.json in its cache directory.  If zero, the
+	// metrics are only available through Metrics and expvar.
+	MetricsInterval time.Duration
+}
+
+// RunWithOptions will initialise the scope runtime and make a scope
+// available, using the given options rather than the command line
+// flags read by Run.  It does not use the flag package.
+//
+// The scope must implement either Scope or ContextScope.  Since it is
+// accepted as a ScopeBaseSetter to allow both, this is only checked
+// when RunWithOptions is called, and an error is returned otherwise.  It will run until the scope is stopped, and
+// then wait for in-flight queries to return before returning.
+func RunWithOptions(scope ScopeBaseSetter, options Options) error {
+	switch scope.(type) {
+	case Scope, ContextScope:
+	default:
+		return fmt.Errorf("%T implements neither Scope nor ContextScope", scope)
+	}
+	scopeId, err := checkOptions(options)
+	if err != nil {
+		return err
+	}
+	if options.LogSinks != nil {
+		SetLogSinks(options.LogSinks...)
+	}
+	timeouts, err := readQueryTimeouts(options.ScopeConfig)
+	if err != nil {
+		return err
+	}
+	setConfigTimeouts(timeouts)
+	setMetricsScopeId(scopeId)
+	setLogScopeId(scopeId)
+	setMetricsInterval(options.MetricsInterval)
+
+	gracePeriod := options.ShutdownGracePeriod
+	if gracePeriod == 0 {
+		gracePeriod = currentShutdownGracePeriod()
 	}
-	scopeId := base[:len(base)-len(".ini")]
 
+	acceptQueries()
 	var errorString *C.char
-	C.run_scope(strData(scopeId), strData(*runtimeConfig), strData(*scopeConfig), unsafe.Pointer(&scope), &errorString)
+	C.run_scope(strData(scopeId), strData(options.RuntimeConfig), strData(options.ScopeConfig), unsafe.Pointer(&scope), &errorString)
+	drainQueries(gracePeriod)
+	clearShutdown()
 	return checkError(errorString)
 }
 
+// checkOptions validates the options passed to RunWithOptions, and
+// returns the ID of the scope.
+func checkOptions(options Options) (scopeId string, err error) {
+	if options.ScopeConfig == "" {
+		return "", errors.New("Scope configuration file not set")
+	}
+	if options.ScopeId != "" {
+		return options.ScopeId, nil
+	}
+	base := path.Base(options.ScopeConfig)
+	if !strings.HasSuffix(base, ".ini") {
+		return "", errors.New("Scope configuration file does not end in '.ini'")
+	}
+	return base[:len(base)-len(".ini")], nil
+}
+
+// DefaultShutdownGracePeriod is the time Run waits for in-flight
+// queries to return after the scope has been stopped, unless
+// configured otherwise.
+const DefaultShutdownGracePeriod = 5 * time.Second
+
+// ErrScopeStopping is reported to the client for queries arriving
+// while the scope shuts down.
+var ErrScopeStopping = errors.New("scope is shutting down")
+
+var (
+	// runningQueries counts the Search and Preview calls in
+	// progress.  Once the scope stops, no further queries are
+	// accepted, and queriesIdle is closed when the last one
+	// returns.  shutdownPending records a Shutdown call that has
+	// not yet been honoured by the end of a run, so that a scope
+	// asked to stop while starting up does not accept queries.
+	runningQueries     int
+	queriesStopped     bool
+	queriesIdle        chan struct{}
+	shutdownPending    bool
+	runningQueriesLock sync.Mutex
+)
+
 var (
-	cancelChannels     = make(map[chan bool]bool)
+	shutdownGracePeriod     = DefaultShutdownGracePeriod
+	shutdownGracePeriodLock sync.Mutex
+)
+
+// SetShutdownGracePeriod sets how long Run waits for in-flight queries
+// to return once the scope has stopped.  It is overridden by a
+// non-zero Options.ShutdownGracePeriod.
+func SetShutdownGracePeriod(period time.Duration) {
+	shutdownGracePeriodLock.Lock()
+	defer shutdownGracePeriodLock.Unlock()
+	shutdownGracePeriod = period
+}
+
+func currentShutdownGracePeriod() time.Duration {
+	shutdownGracePeriodLock.Lock()
+	defer shutdownGracePeriodLock.Unlock()
+	return shutdownGracePeriod
+}
+
+// acceptQueries allows queries to start, once the scope is running,
+// unless Shutdown has already been called.
+func acceptQueries() {
+	runningQueriesLock.Lock()
+	defer runningQueriesLock.Unlock()
+	queriesStopped = shutdownPending
+}
+
+// requestShutdown records a call to Shutdown, and stops accepting
+// queries.
+func requestShutdown() {
+	runningQueriesLock.Lock()
+	shutdownPending = true
+	runningQueriesLock.Unlock()
+	stopQueries()
+}
+
+// clearShutdown forgets the Shutdown calls made before the end of a
+// run.
+func clearShutdown() {
+	runningQueriesLock.Lock()
+	defer runningQueriesLock.Unlock()
+	shutdownPending = false
+}
+
+// startQuery records a query about to run.  It returns false if the
+// scope is shutting down, in which case the query must not run.
+func startQuery(cancel chan bool) bool {
+	runningQueriesLock.Lock()
+	defer runningQueriesLock.Unlock()
+	if queriesStopped {
+		return false
+	}
+	runningQueries++
+	retainCancelChannel(cancel)
+	return true
+}
+
+func finishQuery(cancel chan bool) {
+	releaseCancelChannel(cancel)
+	runningQueriesLock.Lock()
+	defer runningQueriesLock.Unlock()
+	runningQueries--
+	if runningQueries == 0 && queriesIdle != nil {
+		close(queriesIdle)
+		queriesIdle = nil
+	}
+}
+
+// stopQueries stops accepting queries and cancels those in progress.
+func stopQueries() {
+	runningQueriesLock.Lock()
+	queriesStopped = true
+	runningQueriesLock.Unlock()
+	cancelAllQueries()
+}
+
+// drainQueries stops accepting queries, cancels those in progress and
+// waits for them to return, giving up after the grace period.
+func drainQueries(gracePeriod time.Duration) {
+	stopQueries()
+	runningQueriesLock.Lock()
+	if runningQueries == 0 {
+		runningQueriesLock.Unlock()
+		return
+	}
+	if queriesIdle == nil {
+		queriesIdle = make(chan struct{})
+	}
+	idle := queriesIdle
+	runningQueriesLock.Unlock()
+
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+		logf("Queries still running %v after scope shutdown", gracePeriod)
+	}
+}
+
+// Shutdown asks a scope started with Run, RunContext or RunWithOptions
+// to stop.  Queries in progress are cancelled, and Run returns once
+// they have completed or the shutdown grace period has expired.
+//
+// If the scope has not started yet, it is stopped as soon as the scope
+// runtime has been created.  It is safe to call Shutdown from a signal
+// handler goroutine.
+func Shutdown() {
+	requestShutdown()
+	C.stop_scope()
+}
+
+// cancelChannel tracks the state of a query cancellation channel.
+// The channel is referenced by the C++ query object and by the Go
+// goroutine running the query, and is forgotten once both have
+// released it.
+type cancelChannel struct {
+	cancelled bool
+	refs      int
+}
+
+var (
+	cancelChannels     = make(map[chan bool]*cancelChannel)
 	cancelChannelsLock sync.Mutex
 )
 
 //export makeCancelChannel
 func makeCancelChannel() chan bool {
-	ch := make(chan bool, 1)
+	ch := make(chan bool)
 	cancelChannelsLock.Lock()
-	cancelChannels[ch] = true
+	cancelChannels[ch] = &cancelChannel{refs: 1}
 	cancelChannelsLock.Unlock()
 	return ch
 }
 
+// sendCancelChannel cancels a query by closing its cancellation
+// channel, so that every goroutine receiving from it is woken up.
+// Cancelling a query more than once has no further effect.
+//
 //export sendCancelChannel
 func sendCancelChannel(ch chan bool) {
-	ch <- true
+	cancelChannelsLock.Lock()
+	defer cancelChannelsLock.Unlock()
+	if state, ok := cancelChannels[ch]; ok && !state.cancelled {
+		state.cancelled = true
+		close(ch)
+	}
+}
+
+// retainCancelChannel adds a reference to a cancellation channel, to
+// be dropped with releaseCancelChannel.
+func retainCancelChannel(ch chan bool) {
+	cancelChannelsLock.Lock()
+	defer cancelChannelsLock.Unlock()
+	if state, ok := cancelChannels[ch]; ok {
+		state.refs++
+	}
 }
 
 //export releaseCancelChannel
 func releaseCancelChannel(ch chan bool) {
 	cancelChannelsLock.Lock()
-	delete(cancelChannels, ch)
-	cancelChannelsLock.Unlock()
+	defer cancelChannelsLock.Unlock()
+	if state, ok := cancelChannels[ch]; ok {
+		state.refs--
+		if state.refs <= 0 {
+			delete(cancelChannels, ch)
+		}
+	}
+}
+
+// cancelAllQueries cancels every query that is still live.
+func cancelAllQueries() {
+	cancelChannelsLock.Lock()
+	defer cancelChannelsLock.Unlock()
+	for ch, state := range cancelChannels {
+		if !state.cancelled {
+			state.cancelled = true
+			close(ch)
+		}
+	}
+}
+
+// isCancelled reports whether the given cancellation channel has been
+// closed, without blocking.
+func isCancelled(cancelled <-chan bool) bool {
+	select {
+	case <-cancelled:
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,193 @@
+package scopes_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+func (s *S) TestCancelChannelBroadcast(c *C) {
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	c.Check(scopes.IsCancelled(ch), Equals, false)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			<-ch
+			wg.Done()
+		}()
+	}
+	scopes.SendCancelChannel(ch)
+	wg.Wait()
+
+	// Checking the state does not consume the signal.
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+}
+
+func (s *S) TestCancelChannelIdempotent(c *C) {
+	ch := scopes.MakeCancelChannel()
+	scopes.SendCancelChannel(ch)
+	scopes.SendCancelChannel(ch)
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+
+	// Cancelling a released channel is ignored.
+	scopes.ReleaseCancelChannel(ch)
+	scopes.SendCancelChannel(ch)
+}
+
+func (s *S) TestDrainQueries(c *C) {
+	scopes.AcceptQueries()
+	ch := scopes.MakeCancelChannel()
+	c.Assert(scopes.StartQuery(ch), Equals, true)
+	// The C++ query object may go away before the query returns.
+	scopes.ReleaseCancelChannel(ch)
+
+	returned := make(chan bool)
+	go func() {
+		<-ch
+		scopes.FinishQuery(ch)
+		close(returned)
+	}()
+
+	scopes.DrainQueries(5 * time.Second)
+	select {
+	case <-returned:
+	default:
+		c.Fatal("DrainQueries returned before the query")
+	}
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+}
+
+func (s *S) TestDrainQueriesGracePeriod(c *C) {
+	scopes.AcceptQueries()
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	c.Assert(scopes.StartQuery(ch), Equals, true)
+	defer scopes.FinishQuery(ch)
+
+	// A query ignoring cancellation does not block shutdown
+	// forever.
+	start := time.Now()
+	scopes.DrainQueries(10 * time.Millisecond)
+	c.Check(time.Since(start) < 5*time.Second, Equals, true)
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+}
+
+func (s *S) TestDrainQueriesStopsAccepting(c *C) {
+	scopes.AcceptQueries()
+	scopes.DrainQueries(time.Second)
+
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	c.Check(scopes.StartQuery(ch), Equals, false)
+
+	// Queries are accepted again once the scope runs.
+	scopes.AcceptQueries()
+	c.Assert(scopes.StartQuery(ch), Equals, true)
+	scopes.FinishQuery(ch)
+}
+
+type plainScope struct{}
+
+func (scope *plainScope) SetScopeBase(base *scopes.ScopeBase) {}
+
+func (scope *plainScope) Search(query *scopes.CannedQuery, metadata *scopes.SearchMetadata, reply *scopes.SearchReply, cancelled <-chan bool) error {
+	return nil
+}
+
+func (scope *plainScope) Preview(result *scopes.Result, metadata *scopes.ActionMetadata, reply *scopes.PreviewReply, cancelled <-chan bool) error {
+	return nil
+}
+
+type lifecycleScope struct {
+	plainScope
+	startedId string
+	stopped   bool
+	err       error
+}
+
+func (scope *lifecycleScope) Start(scopeId string) error {
+	if scopeId == "panic" {
+		panic("start failed")
+	}
+	scope.startedId = scopeId
+	return scope.err
+}
+
+func (scope *lifecycleScope) Stop() error {
+	scope.stopped = true
+	return scope.err
+}
+
+func (s *S) TestCallScopeStart(c *C) {
+	scope := &lifecycleScope{}
+	c.Check(scopes.CallScopeStart(scope, "myscope"), IsNil)
+	c.Check(scope.startedId, Equals, "myscope")
+
+	scope.err = errors.New("no database")
+	c.Check(scopes.CallScopeStart(scope, "myscope"), ErrorMatches, "no database")
+	c.Check(scopes.CallScopeStart(scope, "panic"), ErrorMatches, "scope panicked: start failed")
+
+	// Scopes without a Start method start successfully.
+	c.Check(scopes.CallScopeStart(&plainScope{}, "myscope"), IsNil)
+}
+
+func (s *S) TestCallScopeStop(c *C) {
+	scope := &lifecycleScope{}
+	c.Check(scopes.CallScopeStop(scope), IsNil)
+	c.Check(scope.stopped, Equals, true)
+
+	scope.err = errors.New("flush failed")
+	c.Check(scopes.CallScopeStop(scope), ErrorMatches, "flush failed")
+
+	c.Check(scopes.CallScopeStop(&plainScope{}), IsNil)
+}
+
+func (s *S) TestCallScopeStopCancelsQueries(c *C) {
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	c.Check(scopes.CallScopeStop(&lifecycleScope{}), IsNil)
+	c.Check(scopes.IsCancelled(ch), Equals, true)
+}
+
+func (s *S) TestCheckOptions(c *C) {
+	_, err := scopes.CheckOptions(scopes.Options{})
+	c.Check(err, ErrorMatches, "Scope configuration file not set")
+
+	_, err = scopes.CheckOptions(scopes.Options{ScopeConfig: "/usr/share/myscope/myscope.conf"})
+	c.Check(err, ErrorMatches, "Scope configuration file does not end in '.ini'")
+
+	scopeId, err := scopes.CheckOptions(scopes.Options{ScopeConfig: "/usr/share/myscope/com.example_myscope.ini"})
+	c.Check(err, IsNil)
+	c.Check(scopeId, Equals, "com.example_myscope")
+
+	// An explicit scope ID lifts the naming requirement.
+	scopeId, err = scopes.CheckOptions(scopes.Options{
+		ScopeConfig: "/usr/share/myscope/myscope.conf",
+		ScopeId:     "com.example_myscope",
+	})
+	c.Check(err, IsNil)
+	c.Check(scopeId, Equals, "com.example_myscope")
+}
+
+func (s *S) TestShutdownBeforeStart(c *C) {
+	// A shutdown requested while the scope starts up is not
+	// forgotten once it is running.
+	scopes.RequestShutdown()
+	scopes.AcceptQueries()
+	ch := scopes.MakeCancelChannel()
+	defer scopes.ReleaseCancelChannel(ch)
+	c.Check(scopes.StartQuery(ch), Equals, false)
+
+	// Once the run has ended, the next one accepts queries.
+	scopes.ClearShutdown()
+	scopes.AcceptQueries()
+	c.Assert(scopes.StartQuery(ch), Equals, true)
+	scopes.FinishQuery(ch)
+}
@@ -0,0 +1,181 @@
+package scopes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResultValidation selects whether a SearchReply checks pushed results
+// against the components of their category's renderer.
+type ResultValidation int
+
+const (
+	// ValidationOff pushes results unchecked.  This is the default.
+	ValidationOff ResultValidation = iota
+
+	// ValidationLog logs a warning through the reply's Logger for
+	// each invalid result, and pushes it anyway.
+	ValidationLog
+
+	// ValidationReject returns a *ResultValidationError from Push
+	// for invalid results, which are not sent to the client.
+	// PushBatch returns a *BatchValidationError instead.
+	ValidationReject
+)
+
+// ResultValidationError describes a result lacking attributes needed
+// by the renderer of its category.
+type ResultValidationError struct {
+	CategoryId string
+	URI        string
+	// Problems holds a description of each component whose
+	// attribute is missing or of the wrong type.
+	Problems []string
+}
+
+func (e *ResultValidationError) Error() string {
+	return fmt.Sprintf("Invalid result %q in category %q: %s", e.URI, e.CategoryId, strings.Join(e.Problems, "; "))
+}
+
+// BatchValidationError is returned by PushBatch when results of the
+// batch were rejected by validation.  The valid results are pushed.
+type BatchValidationError struct {
+	// First is the error for the first rejected result, usually a
+	// *ResultValidationError.
+	First error
+	// Count is the number of rejected results.
+	Count int
+}
+
+func (e *BatchValidationError) Error() string {
+	if e.Count == 1 {
+		return e.First.Error()
+	}
+	return fmt.Sprintf("%v (and %d more invalid results)", e.First, e.Count-1)
+}
+
+// Unwrap returns the error for the first rejected result.
+func (e *BatchValidationError) Unwrap() error {
+	return e.First
+}
+
+// SetResultValidation enables checking that each pushed result carries
+// the attributes mapped by the "components" of its category's renderer
+// template, which the client would otherwise display as blank.  The
+// check only applies to categories registered with this reply.
+func (reply *SearchReply) SetResultValidation(mode ResultValidation) {
+	reply.lock.Lock()
+	defer reply.lock.Unlock()
+	reply.validation = mode
+}
+
+// defaultRendererTemplate is the template the scopes runtime uses for
+// categories registered without one.
+const defaultRendererTemplate = `{"schema-version":1,"template":{"category-layout":"grid"},"components":{"title":"title","art":"art"}}`
+
+// categoryComponents records the renderer templates of the categories
+// registered with a reply, and parses their components on demand.
+type categoryComponents struct {
+	templates  map[string]string
+	components map[string]map[string]RendererComponent
+}
+
+func (c *categoryComponents) register(categoryId, template string) {
+	if c.templates == nil {
+		c.templates = make(map[string]string)
+		c.components = make(map[string]map[string]RendererComponent)
+	}
+	if template == "" {
+		template = defaultRendererTemplate
+	}
+	c.templates[categoryId] = template
+	delete(c.components, categoryId)
+}
+
+// lookup returns the components of a category, or false if the
+// category is unknown or its template can not be parsed.  Parse
+// errors are written to logger.
+func (c *categoryComponents) lookup(categoryId string, logger *Logger) (map[string]RendererComponent, bool) {
+	if components, ok := c.components[categoryId]; ok {
+		return components, components != nil
+	}
+	template, ok := c.templates[categoryId]
+	if !ok {
+		return nil, false
+	}
+	var renderer CategoryRenderer
+	if err := json.Unmarshal([]byte(template), &renderer); err != nil {
+		logger.Warningf("Could not parse renderer template of category %s: %v", categoryId, err)
+	}
+	c.components[categoryId] = renderer.Components
+	return renderer.Components, renderer.Components != nil
+}
+
+// componentTypes holds the JSON type of the attributes expected by the
+// card components.  Other components take a string.
+var componentTypes = map[string]string{
+	"attributes": "array",
+}
+
+func jsonType(data json.RawMessage) string {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "empty"
+	}
+	switch data[0] {
+	case '"':
+		return "string"
+	case '[':
+		return "array"
+	case '{':
+		return "object"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	}
+	return "number"
+}
+
+// validateResult checks the attributes of a result against the
+// components of its category.
+func validateResult(categoryId string, components map[string]RendererComponent, attrs map[string]json.RawMessage) error {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		field := components[name].Field
+		if field == "" {
+			continue
+		}
+		value, ok := attrs[field]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("component %q needs missing attribute %q", name, field))
+			continue
+		}
+		want := componentTypes[name]
+		if want == "" {
+			want = "string"
+		}
+		if got := jsonType(value); got != want {
+			problems = append(problems, fmt.Sprintf("component %q needs attribute %q of type %s, not %s", name, field, want, got))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	var uri string
+	json.Unmarshal(attrs["uri"], &uri)
+	return &ResultValidationError{
+		CategoryId: categoryId,
+		URI:        uri,
+		Problems:   problems,
+	}
+}
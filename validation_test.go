@@ -0,0 +1,87 @@
+package scopes_test
+
+import (
+	. "gopkg.in/check.v1"
+	"launchpad.net/go-unityscopes/v2"
+)
+
+const validationTemplate = `{
+	"schema-version": 1,
+	"template": {"category-layout": "grid"},
+	"components": {
+		"title": "title",
+		"subtitle": "username",
+		"art": {"field": "art", "aspect-ratio": 1.5},
+		"attributes": "details"
+	}
+}`
+
+func (s *S) TestValidateResultValid(c *C) {
+	err := scopes.ValidateResult(validationTemplate, "cat", `{
+		"uri": "http://example.com",
+		"title": "Title",
+		"username": "user",
+		"art": "http://example.com/art.png",
+		"details": [{"value": "one"}]
+	}`)
+	c.Check(err, IsNil)
+}
+
+func (s *S) TestValidateResultMissing(c *C) {
+	err := scopes.ValidateResult(validationTemplate, "cat", `{
+		"uri": "http://example.com",
+		"title": "Title",
+		"art": "http://example.com/art.png"
+	}`)
+	c.Assert(err, FitsTypeOf, &scopes.ResultValidationError{})
+	c.Check(err.(*scopes.ResultValidationError), DeepEquals, &scopes.ResultValidationError{
+		CategoryId: "cat",
+		URI:        "http://example.com",
+		Problems: []string{
+			`component "attributes" needs missing attribute "details"`,
+			`component "subtitle" needs missing attribute "username"`,
+		},
+	})
+	c.Check(err, ErrorMatches, `Invalid result "http://example.com" in category "cat": component "attributes" needs missing attribute "details"; component "subtitle" needs missing attribute "username"`)
+}
+
+func (s *S) TestValidateResultWrongType(c *C) {
+	err := scopes.ValidateResult(validationTemplate, "cat", `{
+		"uri": "http://example.com",
+		"title": 42,
+		"username": "user",
+		"art": "http://example.com/art.png",
+		"details": "one"
+	}`)
+	c.Assert(err, NotNil)
+	c.Check(err.(*scopes.ResultValidationError).Problems, DeepEquals, []string{
+		`component "attributes" needs attribute "details" of type array, not string`,
+		`component "title" needs attribute "title" of type string, not number`,
+	})
+}
+
+func (s *S) TestValidateResultDefaultTemplate(c *C) {
+	err := scopes.ValidateResult("", "cat", `{"uri": "http://example.com", "title": "Title"}`)
+	c.Assert(err, NotNil)
+	c.Check(err.(*scopes.ResultValidationError).Problems, DeepEquals, []string{
+		`component "art" needs missing attribute "art"`,
+	})
+}
+
+func (s *S) TestValidateResultInvalidTemplate(c *C) {
+	c.Check(scopes.ValidateResult("not json", "cat", `{}`), IsNil)
+}
+
+func (s *S) TestBatchValidationError(c *C) {
+	first := &scopes.ResultValidationError{
+		CategoryId: "cat",
+		URI:        "http://example.com",
+		Problems:   []string{`component "title" needs missing attribute "title"`},
+	}
+	err := &scopes.BatchValidationError{First: first, Count: 1}
+	c.Check(err, ErrorMatches, `Invalid result "http://example.com" in category "cat": component "title" needs missing attribute "title"`)
+	c.Check(err.Unwrap(), Equals, first)
+
+	err.Count = 3
+	c.Check(err, ErrorMatches, `Invalid result .* \(and 2 more invalid results\)`)
+}